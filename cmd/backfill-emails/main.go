@@ -0,0 +1,84 @@
+// Command backfill-emails rewrites stored emails into the form
+// auth.NormalizeEmail produces, so accounts created before emails were
+// normalised can still be found by the address their owners type. Emails
+// that can't be normalised, or that would collide with another account,
+// are listed for an admin to fix by hand.
+//
+// It only reports what it would do unless run with -apply.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/joho/godotenv"
+	"github.com/lib/pq"
+)
+
+func main() {
+	apply := flag.Bool("apply", false, "update the emails instead of only listing them")
+	flag.Parse()
+
+	godotenv.Load()
+	db, err := sql.Open("postgres", os.Getenv("DB_URL"))
+	if err != nil {
+		log.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+	queries := database.New(db)
+
+	ctx := context.Background()
+	users, err := queries.ListUserEmails(ctx)
+	if err != nil {
+		log.Fatalf("Error listing users: %v", err)
+	}
+
+	changed, invalid, collisions := 0, 0, 0
+	for _, user := range users {
+		email, err := auth.NormalizeEmail(user.Email)
+		if err != nil {
+			log.Printf("%s: %q can't be normalised, change it by hand", user.ID, user.Email)
+			invalid++
+			continue
+		}
+		if email == user.Email {
+			continue
+		}
+
+		if !*apply {
+			log.Printf("%s: %q would become %q", user.ID, user.Email, email)
+			changed++
+			continue
+		}
+
+		err = queries.SetUserEmail(ctx, database.SetUserEmailParams{
+			ID:    user.ID,
+			Email: email,
+		})
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			log.Printf("%s: %q would become %q, which another account already has", user.ID, user.Email, email)
+			collisions++
+			continue
+		}
+		if err != nil {
+			log.Fatalf("Error updating %s: %v", user.ID, err)
+		}
+		log.Printf("%s: %q is now %q", user.ID, user.Email, email)
+		changed++
+	}
+
+	log.Printf("%d of %d emails changed, %d invalid, %d colliding", changed, len(users), invalid, collisions)
+	if !*apply && changed > 0 {
+		log.Print("Run again with -apply to update them")
+	}
+	if invalid > 0 || collisions > 0 {
+		os.Exit(1)
+	}
+}
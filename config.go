@@ -0,0 +1,274 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/entitlements"
+	"github.com/iamjoona/chippy/internal/mail"
+	"github.com/iamjoona/chippy/internal/oidc"
+	"github.com/iamjoona/chippy/internal/spam"
+	"github.com/iamjoona/chippy/internal/webhook"
+	"golang.org/x/crypto/hkdf"
+)
+
+// envInt reads an integer environment variable, falling back to def when
+// it isn't set.
+func envInt(key string, def int) (int, error) {
+	val := os.Getenv(key)
+	if val == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	return n, nil
+}
+
+// passwordHasherFromEnv builds the password hasher from
+// PASSWORD_HASH_ALGORITHM, BCRYPT_COST, ARGON2_MEMORY_KIB,
+// ARGON2_ITERATIONS and ARGON2_PARALLELISM.
+func passwordHasherFromEnv() (auth.PasswordHasher, error) {
+	hasher := auth.DefaultPasswordHasher
+
+	if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm != "" {
+		hasher.Algorithm = auth.HashAlgorithm(algorithm)
+	}
+
+	cost, err := envInt("BCRYPT_COST", hasher.BcryptCost)
+	if err != nil {
+		return auth.PasswordHasher{}, err
+	}
+	hasher.BcryptCost = cost
+
+	memory, err := envInt("ARGON2_MEMORY_KIB", int(hasher.Argon2.Memory))
+	if err != nil {
+		return auth.PasswordHasher{}, err
+	}
+	iterations, err := envInt("ARGON2_ITERATIONS", int(hasher.Argon2.Iterations))
+	if err != nil {
+		return auth.PasswordHasher{}, err
+	}
+	parallelism, err := envInt("ARGON2_PARALLELISM", int(hasher.Argon2.Parallelism))
+	if err != nil {
+		return auth.PasswordHasher{}, err
+	}
+	if memory < 0 || iterations < 0 || parallelism < 0 || parallelism > 255 {
+		return auth.PasswordHasher{}, fmt.Errorf("argon2 parameters out of range")
+	}
+	hasher.Argon2.Memory = uint32(memory)
+	hasher.Argon2.Iterations = uint32(iterations)
+	hasher.Argon2.Parallelism = uint8(parallelism)
+
+	err = hasher.Validate()
+	if err != nil {
+		return auth.PasswordHasher{}, err
+	}
+	return hasher, nil
+}
+
+// passwordPolicyFromEnv builds the policy for new passwords from
+// PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH, PASSWORD_MIN_ENTROPY_BITS and
+// BREACHED_PASSWORDS_FILE.
+func passwordPolicyFromEnv() (auth.PasswordPolicy, error) {
+	policy := auth.DefaultPasswordPolicy
+
+	minLength, err := envInt("PASSWORD_MIN_LENGTH", policy.MinLength)
+	if err != nil {
+		return auth.PasswordPolicy{}, err
+	}
+	maxLength, err := envInt("PASSWORD_MAX_LENGTH", policy.MaxLength)
+	if err != nil {
+		return auth.PasswordPolicy{}, err
+	}
+	minEntropy, err := envInt("PASSWORD_MIN_ENTROPY_BITS", int(policy.MinEntropyBits))
+	if err != nil {
+		return auth.PasswordPolicy{}, err
+	}
+	if minLength < 1 || maxLength < minLength || minEntropy < 0 {
+		return auth.PasswordPolicy{}, fmt.Errorf("password policy limits out of range")
+	}
+	policy.MinLength = minLength
+	policy.MaxLength = maxLength
+	policy.MinEntropyBits = float64(minEntropy)
+
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		policy.Breached, err = auth.LoadBreachedPasswords(path)
+		if err != nil {
+			return auth.PasswordPolicy{}, fmt.Errorf("loading %s: %w", path, err)
+		}
+	}
+	return policy, nil
+}
+
+type registrationMode string
+
+const (
+	registrationOpen       registrationMode = "open"
+	registrationInviteOnly registrationMode = "invite-only"
+	registrationClosed     registrationMode = "closed"
+)
+
+// registrationModeFromEnv reads REGISTRATION_MODE, which defaults to open.
+func registrationModeFromEnv() (registrationMode, error) {
+	mode := registrationMode(envDefault("REGISTRATION_MODE", string(registrationOpen)))
+	switch mode {
+	case registrationOpen, registrationInviteOnly, registrationClosed:
+		return mode, nil
+	}
+	return "", fmt.Errorf("REGISTRATION_MODE must be 'open', 'invite-only' or 'closed'")
+}
+
+var oidcProviderNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// oidcProvidersFromEnv sets up the identity providers listed in
+// OIDC_PROVIDERS (comma separated). Each provider NAME is configured with
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET,
+// OIDC_<NAME>_SCOPES (space separated, optional) and
+// OIDC_<NAME>_AUTO_PROVISION.
+func oidcProvidersFromEnv(baseURL string) (map[string]*oidcProvider, error) {
+	providers := map[string]*oidcProvider{}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !oidcProviderNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid OIDC provider name %q", name)
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		issuer := os.Getenv(prefix + "ISSUER")
+		clientID := os.Getenv(prefix + "CLIENT_ID")
+		if issuer == "" || clientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID are required", prefix, prefix)
+		}
+
+		providers[name] = &oidcProvider{
+			Name:          name,
+			AutoProvision: os.Getenv(prefix+"AUTO_PROVISION") == "true",
+			Provider: oidc.NewProvider(oidc.Config{
+				Issuer:       issuer,
+				ClientID:     clientID,
+				ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+				RedirectURL:  baseURL + "/api/auth/oidc/" + name + "/callback",
+				Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+			}),
+		}
+	}
+	return providers, nil
+}
+
+// envDefault reads an environment variable, falling back to def when it
+// isn't set.
+func envDefault(key, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return def
+}
+
+// mailSenderFromEnv picks the outbox delivery transport. MAIL_SENDER is
+// "file" (the default, writing .eml files to MAIL_FILE_DIR) or "smtp"
+// (using SMTP_ADDR, SMTP_USERNAME and SMTP_PASSWORD).
+func mailSenderFromEnv(from string) (mail.Sender, error) {
+	switch envDefault("MAIL_SENDER", "file") {
+	case "file":
+		return mail.FileSender{
+			Dir:  envDefault("MAIL_FILE_DIR", "mail"),
+			From: from,
+		}, nil
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		if addr == "" {
+			return nil, fmt.Errorf("SMTP_ADDR is required when MAIL_SENDER is smtp")
+		}
+		return mail.SMTPSender{
+			Addr:     addr,
+			From:     from,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}, nil
+	}
+	return nil, fmt.Errorf("MAIL_SENDER must be 'file' or 'smtp'")
+}
+
+// polkaWebhookVerifierFromEnv reads the Polka signing secrets from
+// POLKA_WEBHOOK_SECRETS (comma separated, so old and new can overlap while
+// rotating) and the replay window from POLKA_WEBHOOK_TOLERANCE_SECONDS.
+// POLKA_KEY is accepted as the only secret for older deployments.
+func polkaWebhookVerifierFromEnv() (webhook.Verifier, error) {
+	var secrets []string
+	for _, secret := range strings.Split(os.Getenv("POLKA_WEBHOOK_SECRETS"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) == 0 && os.Getenv("POLKA_KEY") != "" {
+		secrets = []string{os.Getenv("POLKA_KEY")}
+	}
+	if len(secrets) == 0 {
+		return webhook.Verifier{}, fmt.Errorf("POLKA_WEBHOOK_SECRETS is required")
+	}
+
+	tolerance, err := envInt("POLKA_WEBHOOK_TOLERANCE_SECONDS", int(webhook.DefaultTolerance.Seconds()))
+	if err != nil {
+		return webhook.Verifier{}, err
+	}
+	if tolerance <= 0 {
+		return webhook.Verifier{}, fmt.Errorf("POLKA_WEBHOOK_TOLERANCE_SECONDS must be positive")
+	}
+
+	return webhook.Verifier{
+		Secrets:   secrets,
+		Tolerance: time.Duration(tolerance) * time.Second,
+	}, nil
+}
+
+// entitlementsFromEnv loads the plan catalog from ENTITLEMENTS_FILE, or
+// uses the built-in one when it isn't set.
+func entitlementsFromEnv() (entitlements.Catalog, error) {
+	path := os.Getenv("ENTITLEMENTS_FILE")
+	if path == "" {
+		return entitlements.DefaultCatalog, nil
+	}
+	return entitlements.Load(path)
+}
+
+// spamPipelineFromEnv loads the spam rules from SPAM_RULES_FILE, or uses
+// the built-in ones when it isn't set.
+func spamPipelineFromEnv() (*spam.Pipeline, error) {
+	path := os.Getenv("SPAM_RULES_FILE")
+	if path == "" {
+		return spam.Default(), nil
+	}
+	return spam.Load(path)
+}
+
+// urlSigningKeyFromEnv reads URL_SIGNING_KEY. When it isn't set a key is
+// derived from jwtSecret with HKDF, so a signed link can never double as a
+// JWT signature or the other way round.
+func urlSigningKeyFromEnv(jwtSecret string) ([]byte, error) {
+	if key := os.Getenv("URL_SIGNING_KEY"); key != "" {
+		if key == jwtSecret {
+			return nil, fmt.Errorf("URL_SIGNING_KEY must differ from JWT_SECRET")
+		}
+		return []byte(key), nil
+	}
+
+	key := make([]byte, sha256.Size)
+	_, err := io.ReadFull(hkdf.New(sha256.New, []byte(jwtSecret), nil, []byte("chirpy url signing")), key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
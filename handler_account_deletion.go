@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/iamjoona/chippy/internal/mail"
+)
+
+func (cfg *apiConfig) deleteMeHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+	type response struct {
+		PurgeAfter time.Time `json:"purge_after"`
+	}
+
+	caller, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching user", err)
+		return
+	}
+
+	// a recent login stands in for the password, which accounts created
+	// through an identity provider don't have
+	if !caller.authenticatedWithin(reauthWindow) {
+		if len(params.Password) == 0 {
+			respondWithError(w, http.StatusUnauthorized, "Password is required to delete your account", nil)
+			return
+		}
+		err = cfg.passwordHasher.Check(params.Password, dbUser.HashedPassword)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Incorrect password", err)
+			return
+		}
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting account", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	dbUser, err = qtx.SoftDeleteUser(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting account", err)
+		return
+	}
+
+	err = qtx.RevokeAllRefreshTokensForUser(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking sessions", err)
+		return
+	}
+
+	err = qtx.RevokeAllPersonalAccessTokensForUser(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error revoking tokens", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting account", err)
+		return
+	}
+
+	purgeAfter := dbUser.DeletedAt.Time.Add(cfg.accountDeletionGrace)
+
+	err = cfg.mailer.Send(r.Context(), mail.Message{
+		To:      dbUser.Email,
+		Subject: "Your Chirpy account is scheduled for deletion",
+		Body: fmt.Sprintf(
+			"Your Chirpy account and everything in it will be permanently deleted after %s.\n\n"+
+				"Changed your mind? Log in before then and your account will be restored.\n",
+			purgeAfter.Format(time.RFC1123),
+		),
+	})
+	if err != nil {
+		log.Printf("Error sending account deletion email: %v", err)
+	}
+
+	respondWithJSON(w, http.StatusAccepted, response{PurgeAfter: purgeAfter})
+}
+
+// accountPurger permanently removes accounts whose deletion grace period has
+// passed. Chirps, sessions and tokens go with the user row via ON DELETE
+// CASCADE; login attempts only reference the user loosely so their emails
+// are removed here, as are data export archives.
+type accountPurger struct {
+	cfg      *apiConfig
+	Interval time.Duration
+}
+
+// Run purges expired accounts every Interval until ctx is cancelled.
+func (p *accountPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := p.PurgeExpired(ctx)
+		if err != nil {
+			log.Printf("Error purging deleted accounts: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeExpired deletes every account soft-deleted longer ago than the grace
+// period and returns how many were removed.
+func (p *accountPurger) PurgeExpired(ctx context.Context) (int, error) {
+	tx, err := p.cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := p.cfg.db.WithTx(tx)
+
+	deletedBefore := time.Now().UTC().Add(-p.cfg.accountDeletionGrace)
+
+	// export rows go with the user but their archives live outside the
+	// database
+	blobKeys, err := qtx.ListDataExportBlobsForPurge(ctx, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := qtx.PurgeDeletedUsers(ctx, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	// a blob that can't be deleted keeps the account around until the next
+	// run rather than leaving the archive behind
+	for _, key := range blobKeys {
+		err = p.cfg.blobs.Delete(ctx, key.String)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for _, dbUser := range purged {
+		err = qtx.DeleteLoginAttemptsByEmail(ctx, dbUser.Email)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return len(purged), nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+)
+
+// bootstrapAdmin promotes the account registered with ADMIN_EMAIL to admin
+// while no admin exists yet, so a fresh deployment can get its first
+// administrator without editing the database by hand. The account has to
+// have verified the address first; otherwise whoever signed up with it
+// first would get the role.
+func (cfg *apiConfig) bootstrapAdmin(ctx context.Context) error {
+	if cfg.adminEmail == "" {
+		return nil
+	}
+
+	admins, err := cfg.db.CountUsersByRole(ctx, string(auth.RoleAdmin))
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	user, err := cfg.db.GetUserByEmail(ctx, cfg.adminEmail)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.EmailVerifiedAt.Valid {
+		return nil
+	}
+
+	_, err = cfg.db.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: string(auth.RoleAdmin),
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Promoted %s to admin", user.Email)
+	return nil
+}
+
+// awaitingBootstrapAdmin reports whether email is ADMIN_EMAIL and no admin
+// exists yet.
+func (cfg *apiConfig) awaitingBootstrapAdmin(r *http.Request, email string) (bool, error) {
+	if cfg.adminEmail == "" || email != cfg.adminEmail {
+		return false, nil
+	}
+	admins, err := cfg.db.CountUsersByRole(r.Context(), string(auth.RoleAdmin))
+	if err != nil {
+		return false, err
+	}
+	return admins == 0, nil
+}
+
+func (cfg *apiConfig) setUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+
+	caller, _ := principalFromContext(r.Context())
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	role, ok := auth.ParseRole(params.Role)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Role must be 'user', 'moderator' or 'admin'", nil)
+		return
+	}
+
+	if userID == caller.UserID && role != auth.RoleAdmin {
+		respondWithError(w, http.StatusBadRequest, "Admins can't demote themselves", nil)
+		return
+	}
+
+	dbUser, err := cfg.db.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:   userID,
+		Role: string(role),
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update role", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userFromDB(dbUser))
+}
@@ -1,56 +1,167 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/subscription"
+	"github.com/iamjoona/chippy/internal/webhook"
 )
 
-func (cfg *apiConfig) userUpgradeHandler(w http.ResponseWriter, r *http.Request) {
+// polkaSignatureHeader carries Polka's webhook signature, in the format
+// described in the webhook package.
+const polkaSignatureHeader = "Polka-Signature"
+
+const polkaWebhookMaxBytes = 1 << 20
+
+var errUnknownWebhookUser = errors.New("webhook names a user that doesn't exist")
 
-	// read auth header and compare to polkaApiKey
-	reqApiKey, err := auth.GetAPIKey(r.Header)
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID string `json:"user_id"`
+		// PeriodEnd is when an upgrade or renewal runs out.
+		PeriodEnd *time.Time `json:"period_end"`
+		Trial     bool       `json:"trial"`
+	} `json:"data"`
+}
+
+// polkaSubscriptionEvents maps the Polka events we act on to subscription
+// changes; anything else is recorded and ignored.
+var polkaSubscriptionEvents = map[string]subscription.Event{
+	"user.upgraded":       subscription.EventUpgraded,
+	"user.renewed":        subscription.EventRenewed,
+	"user.payment_failed": subscription.EventPaymentFailed,
+	"user.downgraded":     subscription.EventDowngraded,
+}
+
+func (cfg *apiConfig) userUpgradeHandler(w http.ResponseWriter, r *http.Request) {
+	// the signature covers the raw bytes, so read them before decoding
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, polkaWebhookMaxBytes))
 	if err != nil {
-		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if reqApiKey != cfg.polkaApiKey {
-		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+	err = cfg.polkaWebhooks.Verify(r.Header.Get(polkaSignatureHeader), body, time.Now())
+	if err != nil {
+		log.Printf("Rejected Polka webhook: %v", err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
 		return
 	}
 
-	var upgradeRequest struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
-	}
-	err = json.NewDecoder(r.Body).Decode(&upgradeRequest)
+	var event polkaEvent
+	err = json.Unmarshal(body, &event)
 	if err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if upgradeRequest.Event != "user.upgraded" {
-		http.Error(w, "Invalid event type", http.StatusNoContent)
-		return
+	eventID := event.ID
+	if eventID == "" {
+		// events without an ID are identified by the signed content. The
+		// body alone isn't enough: a second upgrade or a monthly renewal
+		// can repeat it exactly, but it is signed at a different time.
+		signedAt, err := webhook.Timestamp(r.Header.Get(polkaSignatureHeader))
+		if err != nil {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		sum := sha256.Sum256([]byte(signedAt + "." + string(body)))
+		eventID = "sha256:" + hex.EncodeToString(sum[:])
 	}
 
-	userID, err := uuid.Parse(upgradeRequest.Data.UserID)
+	stored, err := cfg.recordWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		ID:        uuid.New(),
+		Provider:  webhookProviderPolka,
+		EventID:   eventID,
+		EventType: event.Event,
+		Payload:   body,
+	})
 	if err != nil {
-		http.Error(w, "User can't be found", http.StatusNotFound)
+		http.Error(w, "Error recording event", http.StatusInternalServerError)
 		return
 	}
 
-	_, err = cfg.db.UpgradeUserToChirpyRed(r.Context(), userID)
+	_, err = cfg.processWebhookEvent(r.Context(), stored, false)
+	if errors.Is(err, errWebhookEventHandled) {
+		// a retry of an event we already handled
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if errors.Is(err, errWebhookEventInProgress) {
+		// not acknowledged, so Polka retries in case this attempt dies
+		http.Error(w, "Event is being processed", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, subscription.ErrInvalidTransition) {
+		// retrying won't make it apply, so don't ask Polka to
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if errors.Is(err, errUnknownWebhookUser) {
+		http.Error(w, "User can't be found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error upgrading user", http.StatusInternalServerError)
+		http.Error(w, "Error updating subscription", http.StatusInternalServerError)
 		return
 	}
 
-	respondWithJSON(w, http.StatusNoContent, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyPolkaEvent acts on a stored Polka event and returns its outcome.
+func (cfg *apiConfig) applyPolkaEvent(ctx context.Context, payload json.RawMessage) (string, error) {
+	var event polkaEvent
+	err := json.Unmarshal(payload, &event)
+	if err != nil {
+		return webhookFailed, err
+	}
+
+	subscriptionEvent, ok := polkaSubscriptionEvents[event.Event]
+	if !ok {
+		return webhookIgnored, nil
+	}
+
+	userID, err := uuid.Parse(event.Data.UserID)
+	if err != nil {
+		return webhookFailed, errUnknownWebhookUser
+	}
+
+	change := subscription.Change{
+		Event: subscriptionEvent,
+		Trial: event.Data.Trial,
+	}
+	if event.Data.PeriodEnd != nil {
+		change.PeriodEnd = event.Data.PeriodEnd.UTC()
+	}
+
+	err = cfg.changeSubscription(ctx, userID, change)
+	if err == sql.ErrNoRows {
+		return webhookFailed, errUnknownWebhookUser
+	}
+	if errors.Is(err, subscription.ErrInvalidTransition) {
+		return webhookIgnored, err
+	}
+	if err != nil {
+		return webhookFailed, err
+	}
+
+	if subscriptionEvent == subscription.EventUpgraded {
+		cfg.publishEvent(ctx, eventUserUpgraded, userID, map[string]uuid.UUID{"user_id": userID})
+	}
 
+	return webhookProcessed, nil
 }
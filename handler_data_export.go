@@ -0,0 +1,395 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/database"
+)
+
+const (
+	// dataExportRetention is how long a finished archive is kept.
+	dataExportRetention = 7 * 24 * time.Hour
+	// dataExportLinkTTL is how long a download link stays valid.
+	dataExportLinkTTL = 15 * time.Minute
+	// dataExportStaleAfter is how long an export can stay running before
+	// another worker assumes the one building it died and starts over.
+	dataExportStaleAfter = 15 * time.Minute
+)
+
+type DataExport struct {
+	ID          uuid.UUID  `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+func (cfg *apiConfig) dataExportFromDB(export database.DataExport) DataExport {
+	formatted := DataExport{
+		ID:        export.ID,
+		Status:    export.Status,
+		CreatedAt: export.CreatedAt,
+	}
+	if export.CompletedAt.Valid {
+		formatted.CompletedAt = &export.CompletedAt.Time
+	}
+	if export.ExpiresAt.Valid {
+		formatted.ExpiresAt = &export.ExpiresAt.Time
+	}
+	if export.Status == "completed" {
+		path := dataExportDownloadPath(export.ID)
+		formatted.DownloadURL = cfg.baseURL + path + "?" + cfg.urlSigner.Sign(path, time.Now().Add(dataExportLinkTTL))
+	}
+	return formatted
+}
+
+func dataExportDownloadPath(exportID uuid.UUID) string {
+	return "/api/exports/" + exportID.String() + "/download"
+}
+
+func (cfg *apiConfig) createDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	// asking again while an export is queued just returns that one
+	export, err := cfg.db.GetUnfinishedDataExportForUser(r.Context(), caller.UserID)
+	if err == nil {
+		respondWithJSON(w, http.StatusAccepted, cfg.dataExportFromDB(export))
+		return
+	}
+	if err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching exports", err)
+		return
+	}
+
+	export, err = cfg.db.CreateDataExport(r.Context(), database.CreateDataExportParams{
+		ID:     uuid.New(),
+		UserID: caller.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create export", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, cfg.dataExportFromDB(export))
+}
+
+func (cfg *apiConfig) getDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid export ID", err)
+		return
+	}
+
+	export, err := cfg.db.GetDataExport(r.Context(), database.GetDataExportParams{
+		ID:     exportID,
+		UserID: caller.UserID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Export not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching export", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, cfg.dataExportFromDB(export))
+}
+
+// downloadDataExportHandler is authorised by the signed link handed out by
+// getDataExportHandler rather than by a bearer token, so it works from a
+// plain browser download.
+func (cfg *apiConfig) downloadDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid export ID", err)
+		return
+	}
+
+	err = cfg.urlSigner.Verify(dataExportDownloadPath(exportID), r.URL.Query(), time.Now())
+	if err != nil {
+		respondWithError(w, http.StatusForbidden, "Download link is invalid or has expired", err)
+		return
+	}
+
+	export, err := cfg.db.GetDataExportByID(r.Context(), exportID)
+	if err == sql.ErrNoRows || (err == nil && (export.Status != "completed" || !export.BlobKey.Valid)) {
+		respondWithError(w, http.StatusNotFound, "Export not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching export", err)
+		return
+	}
+
+	rc, err := cfg.blobs.Open(r.Context(), export.BlobKey.String)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error opening export", err)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, export.CompletedAt.Time.Format("2006-01-02")))
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, rc)
+	if err != nil {
+		log.Printf("Error streaming export %s: %v", export.ID, err)
+	}
+}
+
+// dataExportWorker builds queued exports in the background and removes
+// archives once they expire.
+type dataExportWorker struct {
+	cfg      *apiConfig
+	Interval time.Duration
+}
+
+// Run processes exports every Interval until ctx is cancelled.
+func (wk *dataExportWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(wk.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := wk.ProcessPending(ctx)
+		if err != nil {
+			log.Printf("Error processing data exports: %v", err)
+		}
+		err = wk.DeleteExpired(ctx)
+		if err != nil {
+			log.Printf("Error deleting expired data exports: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessPending builds every queued export, along with any left running
+// by a worker that stopped. A failed export is marked as such and doesn't
+// stop the others.
+func (wk *dataExportWorker) ProcessPending(ctx context.Context) error {
+	for {
+		export, err := wk.cfg.db.ClaimPendingDataExport(ctx, time.Now().UTC().Add(-dataExportStaleAfter))
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		key := "exports/" + export.ID.String() + ".zip"
+		err = wk.build(ctx, export.UserID, key)
+		if err != nil {
+			log.Printf("Error building data export %s: %v", export.ID, err)
+			err = wk.cfg.db.FailDataExport(ctx, database.FailDataExportParams{
+				ID:    export.ID,
+				Error: sql.NullString{String: err.Error(), Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = wk.cfg.db.CompleteDataExport(ctx, database.CompleteDataExportParams{
+			ID:        export.ID,
+			BlobKey:   sql.NullString{String: key, Valid: true},
+			ExpiresAt: sql.NullTime{Time: time.Now().UTC().Add(dataExportRetention), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// DeleteExpired removes expired exports and their archives.
+func (wk *dataExportWorker) DeleteExpired(ctx context.Context) error {
+	expired, err := wk.cfg.db.DeleteExpiredDataExports(ctx)
+	if err != nil {
+		return err
+	}
+	for _, export := range expired {
+		if !export.BlobKey.Valid {
+			continue
+		}
+		err = wk.cfg.blobs.Delete(ctx, export.BlobKey.String)
+		if err != nil {
+			log.Printf("Error deleting export archive %s: %v", export.BlobKey.String, err)
+		}
+	}
+	return nil
+}
+
+func (wk *dataExportWorker) build(ctx context.Context, userID uuid.UUID, key string) error {
+	files, err := wk.cfg.collectUserData(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = writeExportArchive(&buf, files)
+	if err != nil {
+		return err
+	}
+	return wk.cfg.blobs.Put(ctx, key, &buf)
+}
+
+type exportedUser struct {
+	ID              uuid.UUID  `json:"id"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PendingEmail    string     `json:"pending_email,omitempty"`
+	Role            string     `json:"role"`
+	Handle          string     `json:"handle,omitempty"`
+	DisplayName     string     `json:"display_name,omitempty"`
+	Bio             string     `json:"bio,omitempty"`
+	AvatarURL       string     `json:"avatar_url,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type exportedSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+type exportedSubscription struct {
+	IsChirpyRed      bool       `json:"is_chirpy_red"`
+	Status           string     `json:"status,omitempty"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end,omitempty"`
+	CanceledAt       *time.Time `json:"canceled_at,omitempty"`
+}
+
+// collectUserData gathers everything held about a user, keyed by the file
+// name it is written to in the archive. Secrets such as password hashes and
+// token values are left out.
+func (cfg *apiConfig) collectUserData(ctx context.Context, userID uuid.UUID) (map[string]any, error) {
+	dbUser, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user := exportedUser{
+		ID:           dbUser.ID,
+		Email:        dbUser.Email,
+		PendingEmail: dbUser.PendingEmail.String,
+		Role:         dbUser.Role,
+		Handle:       dbUser.Handle.String,
+		DisplayName:  dbUser.DisplayName.String,
+		Bio:          dbUser.Bio.String,
+		AvatarURL:    dbUser.AvatarUrl.String,
+		CreatedAt:    dbUser.CreatedAt,
+		UpdatedAt:    dbUser.UpdatedAt,
+	}
+	if dbUser.EmailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &dbUser.EmailVerifiedAt.Time
+	}
+
+	dbChirps, err := cfg.db.ListAllChirpsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chirps := make([]Chirp, len(dbChirps))
+	for i, chirp := range dbChirps {
+		chirps[i] = Chirp{
+			ID:        chirp.ID,
+			Body:      chirp.Body,
+			CreatedAt: chirp.CreatedAt,
+			UpdatedAt: chirp.UpdatedAt,
+			UserID:    chirp.UserID,
+		}
+	}
+
+	refreshTokens, err := cfg.db.ListRefreshTokensByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]exportedSession, len(refreshTokens))
+	for i, token := range refreshTokens {
+		sessions[i] = exportedSession{
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+		}
+		if token.RevokedAt.Valid {
+			sessions[i].RevokedAt = &token.RevokedAt.Time
+		}
+	}
+
+	dbIdentities, err := cfg.db.ListExternalIdentitiesByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	identities := make([]ExternalIdentity, len(dbIdentities))
+	for i, identity := range dbIdentities {
+		identities[i] = externalIdentityFromDB(identity)
+	}
+
+	subscription := exportedSubscription{IsChirpyRed: dbUser.IsChirpyRed}
+	dbSubscription, err := cfg.db.GetSubscriptionByUserID(ctx, userID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		subscription.Status = dbSubscription.Status
+		subscription.CurrentPeriodEnd = &dbSubscription.CurrentPeriodEnd
+		if dbSubscription.CanceledAt.Valid {
+			subscription.CanceledAt = &dbSubscription.CanceledAt.Time
+		}
+	}
+
+	return map[string]any{
+		"identities.json":   identities,
+		"user.json":         user,
+		"chirps.json":       chirps,
+		"sessions.json":     sessions,
+		"subscription.json": subscription,
+	}, nil
+}
+
+func writeExportArchive(w io.Writer, files map[string]any) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(files[name])
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
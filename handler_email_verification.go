@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/mail"
+)
+
+const emailVerificationTokenTTL = 24 * time.Hour
+
+// sendEmailVerification emails a confirmation link for email, which is
+// either the user's unverified address or the one they want to change to.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/app/verify-email?token=%s", cfg.baseURL, url.QueryEscape(token))
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Confirm your email for Chirpy",
+		Body: fmt.Sprintf(
+			"Confirm this address for your Chirpy account by opening the link below within 24 hours:\n%s\n\n"+
+				"If you didn't ask for this, you can ignore this email.\n",
+			link,
+		),
+	})
+}
+
+// stageEmailChange records email as pending through q, so it can share a
+// transaction with other changes. The account keeps its current email until
+// the new address confirms the link sendEmailVerification sends it.
+func stageEmailChange(ctx context.Context, q *database.Queries, userID uuid.UUID, email string) error {
+	existing, err := q.GetUserByEmail(ctx, email)
+	if err == nil && existing.ID != userID {
+		return errEmailTaken
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = q.SetUserPendingEmail(ctx, database.SetUserPendingEmailParams{
+		ID:           userID,
+		PendingEmail: sql.NullString{String: email, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	// links sent for an earlier pending address stop working
+	return q.InvalidateEmailVerificationTokensForUser(ctx, userID)
+}
+
+func (cfg *apiConfig) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if len(params.Token) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Token is required", nil)
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	verification, err := qtx.ConsumeEmailVerificationToken(r.Context(), auth.HashToken(params.Token))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired verification token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+
+	dbUser, err := qtx.MarkUserEmailVerified(r.Context(), database.MarkUserEmailVerifiedParams{
+		ID:    verification.UserID,
+		Email: verification.Email,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email is already in use", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+
+	err = qtx.InvalidateEmailVerificationTokensForUser(r.Context(), verification.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+
+	err = cfg.bootstrapAdmin(r.Context())
+	if err != nil {
+		log.Printf("Error bootstrapping admin: %v", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, userFromDB(dbUser))
+}
+
+func (cfg *apiConfig) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.requireScope(w, r, auth.ScopeProfileWrite)
+	if !ok {
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching user", err)
+		return
+	}
+
+	email := dbUser.PendingEmail.String
+	if !dbUser.PendingEmail.Valid {
+		if dbUser.EmailVerifiedAt.Valid {
+			respondWithError(w, http.StatusConflict, "Email is already verified", nil)
+			return
+		}
+		email = dbUser.Email
+	}
+
+	err = cfg.db.InvalidateEmailVerificationTokensForUser(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send verification email", err)
+		return
+	}
+
+	err = cfg.sendEmailVerification(r.Context(), dbUser.ID, email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send verification email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
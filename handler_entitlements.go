@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/iamjoona/chippy/internal/entitlements"
+)
+
+// planOf is the plan a user is on. Chirpy Red follows the subscription
+// state, see updateSubscription.
+func planOf(isChirpyRed bool) entitlements.Plan {
+	if isChirpyRed {
+		return entitlements.PlanChirpyRed
+	}
+	return entitlements.PlanFree
+}
+
+func (cfg *apiConfig) entitlementsOf(p principal) entitlements.Entitlements {
+	return cfg.entitlements.For(planOf(p.IsChirpyRed))
+}
+
+// requireCapability checks the caller's plan includes capability, writing
+// the error response itself when it returns false. Handlers for features a
+// plan can switch off (editing, pinning, media uploads) must call it before
+// doing anything else, so what a plan allows stays defined in one place.
+func (cfg *apiConfig) requireCapability(w http.ResponseWriter, p principal, capability entitlements.Capability) bool {
+	if !cfg.entitlementsOf(p).Has(capability) {
+		respondWithError(w, http.StatusForbidden, "Your plan doesn't include "+string(capability), nil)
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) getEntitlementsHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Plan         entitlements.Plan            `json:"plan"`
+		Capabilities []string                     `json:"capabilities"`
+		Limits       map[entitlements.Limit]int64 `json:"limits"`
+	}
+
+	p, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return
+	}
+
+	e := cfg.entitlementsOf(p)
+	limits := make(map[entitlements.Limit]int64, len(entitlements.AllLimits))
+	for _, limit := range entitlements.AllLimits {
+		limits[limit] = e.Limit(limit)
+	}
+
+	respondWithJSON(w, http.StatusOK, response{
+		Plan:         e.Plan,
+		Capabilities: e.CapabilityNames(),
+		Limits:       limits,
+	})
+}
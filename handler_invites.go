@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+)
+
+// Limits on invites created by regular users; admins aren't limited.
+const (
+	userInviteMaxUses     = 5
+	userInviteMaxActive   = 5
+	userInviteDefaultTTL  = 7 * 24 * time.Hour
+	userInviteMaxLifetime = 30 * 24 * time.Hour
+)
+
+type Invite struct {
+	ID        uuid.UUID  `json:"id"`
+	Code      string     `json:"code"`
+	CreatedBy uuid.UUID  `json:"created_by"`
+	MaxUses   int32      `json:"max_uses"`
+	Uses      int32      `json:"uses"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func inviteFromDB(invite database.InviteCode) Invite {
+	formatted := Invite{
+		ID:        invite.ID,
+		Code:      invite.Code,
+		CreatedBy: invite.CreatedBy,
+		MaxUses:   invite.MaxUses,
+		Uses:      invite.Uses,
+		CreatedAt: invite.CreatedAt,
+	}
+	if invite.ExpiresAt.Valid {
+		formatted.ExpiresAt = &invite.ExpiresAt.Time
+	}
+	if invite.RevokedAt.Valid {
+		formatted.RevokedAt = &invite.RevokedAt.Time
+	}
+	return formatted
+}
+
+type createInviteRequest struct {
+	MaxUses          int `json:"max_uses"`
+	ExpiresInSeconds int `json:"expires_in_seconds"`
+}
+
+func (cfg *apiConfig) createInvite(ctx context.Context, createdBy uuid.UUID, maxUses int, expiresIn time.Duration) (database.InviteCode, error) {
+	code, err := auth.MakeInviteCode()
+	if err != nil {
+		return database.InviteCode{}, err
+	}
+
+	expiresAt := sql.NullTime{}
+	if expiresIn > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().UTC().Add(expiresIn), Valid: true}
+	}
+
+	return cfg.db.CreateInviteCode(ctx, database.CreateInviteCodeParams{
+		ID:        uuid.New(),
+		Code:      code,
+		CreatedBy: createdBy,
+		MaxUses:   int32(maxUses),
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (cfg *apiConfig) createInviteHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	if cfg.registration == registrationClosed {
+		respondWithError(w, http.StatusForbidden, "Registration is closed", nil)
+		return
+	}
+
+	if !caller.EmailVerified {
+		respondWithError(w, http.StatusForbidden, "Verify your email before inviting people", nil)
+		return
+	}
+
+	params := createInviteRequest{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if params.MaxUses == 0 {
+		params.MaxUses = 1
+	}
+	if params.MaxUses < 0 || params.MaxUses > userInviteMaxUses {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("max_uses must be between 1 and %d", userInviteMaxUses), nil)
+		return
+	}
+
+	expiresIn := time.Duration(params.ExpiresInSeconds) * time.Second
+	if expiresIn == 0 {
+		expiresIn = userInviteDefaultTTL
+	}
+	if expiresIn < 0 || expiresIn > userInviteMaxLifetime {
+		respondWithError(w, http.StatusBadRequest, "expires_in_seconds must be positive and at most 30 days", nil)
+		return
+	}
+
+	active, err := cfg.db.CountActiveInviteCodesByCreator(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error counting invites", err)
+		return
+	}
+	if active >= userInviteMaxActive {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("You can have at most %d active invites", userInviteMaxActive), nil)
+		return
+	}
+
+	invite, err := cfg.createInvite(r.Context(), caller.UserID, params.MaxUses, expiresIn)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create invite", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, inviteFromDB(invite))
+}
+
+func (cfg *apiConfig) listInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	invites, err := cfg.db.ListInviteCodesByCreator(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching invites", err)
+		return
+	}
+
+	formatted := make([]Invite, len(invites))
+	for i, invite := range invites {
+		formatted[i] = inviteFromDB(invite)
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+func (cfg *apiConfig) revokeInviteHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+	cfg.revokeInvite(w, r, caller)
+}
+
+// revokeInvite revokes the invite named in the path. Only its creator or
+// an admin may do so.
+func (cfg *apiConfig) revokeInvite(w http.ResponseWriter, r *http.Request, caller principal) {
+	inviteID, err := uuid.Parse(r.PathValue("inviteID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid invite ID", err)
+		return
+	}
+
+	invite, err := cfg.db.GetInviteCodeByID(r.Context(), inviteID)
+	if err == sql.ErrNoRows || (err == nil && invite.CreatedBy != caller.UserID && !caller.hasRole(auth.RoleAdmin)) {
+		respondWithError(w, http.StatusNotFound, "Invite not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching invite", err)
+		return
+	}
+
+	_, err = cfg.db.RevokeInviteCode(r.Context(), invite.ID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke invite", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) adminCreateInviteHandler(w http.ResponseWriter, r *http.Request) {
+	caller, _ := principalFromContext(r.Context())
+
+	params := createInviteRequest{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if params.MaxUses == 0 {
+		params.MaxUses = 1
+	}
+	if params.MaxUses < 0 {
+		respondWithError(w, http.StatusBadRequest, "max_uses can't be negative", nil)
+		return
+	}
+	if params.ExpiresInSeconds < 0 {
+		respondWithError(w, http.StatusBadRequest, "expires_in_seconds can't be negative", nil)
+		return
+	}
+
+	invite, err := cfg.createInvite(r.Context(), caller.UserID, params.MaxUses, time.Duration(params.ExpiresInSeconds)*time.Second)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create invite", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, inviteFromDB(invite))
+}
+
+func (cfg *apiConfig) adminListInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	invites, err := cfg.db.ListInviteCodes(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching invites", err)
+		return
+	}
+
+	formatted := make([]Invite, len(invites))
+	for i, invite := range invites {
+		formatted[i] = inviteFromDB(invite)
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+func (cfg *apiConfig) adminRevokeInviteHandler(w http.ResponseWriter, r *http.Request) {
+	caller, _ := principalFromContext(r.Context())
+	cfg.revokeInvite(w, r, caller)
+}
+
+func (cfg *apiConfig) adminListInviteesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	invitees, err := cfg.db.ListUsersInvitedBy(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching invitees", err)
+		return
+	}
+
+	formatted := make([]User, len(invitees))
+	for i, invitee := range invitees {
+		formatted[i] = userFromDB(invitee)
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
@@ -1,24 +1,30 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/iamjoona/chippy/internal/auth"
 	"github.com/iamjoona/chippy/internal/database"
 )
 
+// loginResponse is what every way of logging in returns.
+type loginResponse struct {
+	User
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
 func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Password string `json:"password"`
 		Email    string `json:"email"`
 	}
-	type response struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-	}
 
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
@@ -28,53 +34,118 @@ func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
+	email, err := auth.NormalizeEmail(params.Email)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid email address", err)
 		return
 	}
 
-	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
-	if err != nil {
+	user, retryAfter, err := cfg.checkPassword(r.Context(), email, params.Password, clientIP(r))
+	if retryAfter > 0 {
+		respondTooManyLoginAttempts(w, retryAfter)
+		return
+	}
+	if errors.Is(err, errIncorrectPassword) {
 		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
-
-	accessToken, err := auth.MakeJWT(
-		user.ID,
-		cfg.jwtSecret,
-		time.Hour,
-	)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check login attempts", err)
 		return
 	}
 
-	refreshToken, err := auth.MakeRefreshToken()
+	cfg.completeLogin(w, r, user)
+}
+
+var errIncorrectPassword = errors.New("incorrect email or password")
+
+// checkPassword verifies a password login, applying the login throttle and
+// recording the attempt. retryAfter is non-zero when the caller is locked
+// out; a wrong email or password returns errIncorrectPassword.
+func (cfg *apiConfig) checkPassword(ctx context.Context, email, password, ip string) (user database.User, retryAfter time.Duration, err error) {
+	retryAfter, err = cfg.loginRetryAfter(ctx, email, ip)
+	if err != nil || retryAfter > 0 {
+		return database.User{}, retryAfter, err
+	}
+
+	user, err = cfg.db.GetUserByEmail(ctx, email)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
+		// compare against a throwaway hash so unknown emails take as long
+		// as a wrong password does
+		cfg.passwordHasher.Check(password, cfg.dummyPasswordHash)
+		cfg.recordLoginAttempt(ctx, email, ip, uuid.NullUUID{}, false)
+		return database.User{}, 0, errIncorrectPassword
+	}
+
+	// accounts provisioned through an identity provider have no password,
+	// and an empty hash fails without any hashing, so check the throwaway
+	// hash to keep them from standing out by how fast they are turned away
+	if user.HashedPassword == "" {
+		cfg.passwordHasher.Check(password, cfg.dummyPasswordHash)
+		cfg.recordLoginAttempt(ctx, email, ip, uuid.NullUUID{UUID: user.ID, Valid: true}, false)
+		return database.User{}, 0, errIncorrectPassword
+	}
+
+	err = cfg.passwordHasher.Check(password, user.HashedPassword)
+	if err != nil {
+		cfg.recordLoginAttempt(ctx, email, ip, uuid.NullUUID{UUID: user.ID, Valid: true}, false)
+		return database.User{}, 0, errIncorrectPassword
+	}
+
+	cfg.recordLoginAttempt(ctx, email, ip, uuid.NullUUID{UUID: user.ID, Valid: true}, true)
+
+	// upgrade hashes made with an older algorithm or weaker parameters
+	// while we have the plaintext password
+	if cfg.passwordHasher.NeedsRehash(user.HashedPassword) {
+		cfg.rehashPassword(ctx, user.ID, password)
+	}
+
+	return user, 0, nil
+}
+
+// completeLogin starts a session for a user who has just proved who they
+// are and writes the loginResponse.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	var err error
+
+	if cfg.rejectSuspended(w, r, user.ID) {
 		return
 	}
 
-	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().UTC().Add(time.Hour * 24 * 60),
-	})
+	// logging in during the deletion grace period cancels the deletion
+	if user.DeletedAt.Valid {
+		user, err = cfg.db.RestoreUser(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't restore account", err)
+			return
+		}
+	}
+
+	accessToken, refreshToken, err := cfg.issueSession(r.Context(), user.ID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create session", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, response{
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		},
+	respondWithJSON(w, http.StatusOK, loginResponse{
+		User:         userFromDB(user),
 		Token:        accessToken,
 		RefreshToken: refreshToken,
 	})
 }
+
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password string) {
+	hashedPassword, err := cfg.passwordHasher.Hash(password)
+	if err != nil {
+		log.Printf("Error rehashing password: %v", err)
+		return
+	}
+
+	err = cfg.db.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             userID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		log.Printf("Error saving rehashed password: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/mail"
+)
+
+const (
+	magicLinkTokenTTL = 15 * time.Minute
+	// magicLinksPerHour caps how many links one account is sent, so the
+	// endpoint can't be used to flood someone's inbox.
+	magicLinksPerHour = 5
+)
+
+func (cfg *apiConfig) requestMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if len(params.Email) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Email is required", nil)
+		return
+	}
+
+	email, err := auth.NormalizeEmail(params.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid email address", err)
+		return
+	}
+
+	// like the password reset, the response never reveals whether the
+	// email is registered
+	user, err := cfg.db.GetUserByEmail(r.Context(), email)
+	if err == nil {
+		err = cfg.sendMagicLink(r, user)
+		if err != nil {
+			log.Printf("Error sending magic link: %v", err)
+		}
+	} else if err != sql.ErrNoRows {
+		log.Printf("Error looking up user for magic link: %v", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) sendMagicLink(r *http.Request, user database.User) error {
+	recent, err := cfg.db.CountMagicLinkTokensSince(r.Context(), database.CountMagicLinkTokensSinceParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().UTC().Add(-time.Hour),
+	})
+	if err != nil {
+		return err
+	}
+	if recent >= magicLinksPerHour {
+		log.Printf("Not sending magic link to %s: hourly limit reached", user.ID)
+		return nil
+	}
+
+	token, err := auth.MakeOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.CreateMagicLinkToken(r.Context(), database.CreateMagicLinkTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(magicLinkTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/app/magic-login?token=%s", cfg.baseURL, url.QueryEscape(token))
+	return cfg.mailer.Send(r.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy sign-in link",
+		Body: fmt.Sprintf(
+			"Use this link within 15 minutes to sign in to Chirpy. It only works once:\n%s\n\n"+
+				"If you didn't ask to sign in, you can ignore this email.\n",
+			link,
+		),
+	})
+}
+
+func (cfg *apiConfig) exchangeMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if len(params.Token) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Token is required", nil)
+		return
+	}
+
+	magicLink, err := cfg.db.ConsumeMagicLinkToken(r.Context(), auth.HashToken(params.Token))
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired sign-in link", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check sign-in link", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), magicLink.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching user", err)
+		return
+	}
+
+	cfg.completeLogin(w, r, user)
+}
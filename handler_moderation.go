@@ -0,0 +1,535 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/mail"
+)
+
+// Report statuses, derived from whether a report has been resolved.
+const (
+	reportOpen     = "open"
+	reportResolved = "resolved"
+)
+
+// Actions recorded in the moderation audit trail.
+const (
+	moderationAssign        = "assign"
+	moderationDismiss       = "dismiss"
+	moderationHideChirp     = "hide_chirp"
+	moderationDeleteChirp   = "delete_chirp"
+	moderationWarnAuthor    = "warn_author"
+	moderationSuspendAuthor = "suspend_author"
+)
+
+// resolutionActions are the actions that close a report.
+var resolutionActions = []string{
+	moderationDismiss,
+	moderationHideChirp,
+	moderationDeleteChirp,
+	moderationWarnAuthor,
+	moderationSuspendAuthor,
+}
+
+const (
+	moderationDefaultLimit = 50
+	moderationMaxLimit     = 200
+)
+
+type ModerationAction struct {
+	ID           uuid.UUID  `json:"id"`
+	ModeratorID  *uuid.UUID `json:"moderator_id"`
+	Action       string     `json:"action"`
+	ReportID     *uuid.UUID `json:"report_id"`
+	ChirpID      *uuid.UUID `json:"chirp_id"`
+	TargetUserID *uuid.UUID `json:"target_user_id"`
+	Note         string     `json:"note"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func moderationActionFromDB(action database.ModerationAction) ModerationAction {
+	formatted := ModerationAction{
+		ID:        action.ID,
+		Action:    action.Action,
+		Note:      action.Note,
+		CreatedAt: action.CreatedAt,
+	}
+	if action.ModeratorID.Valid {
+		formatted.ModeratorID = &action.ModeratorID.UUID
+	}
+	if action.ReportID.Valid {
+		formatted.ReportID = &action.ReportID.UUID
+	}
+	if action.ChirpID.Valid {
+		formatted.ChirpID = &action.ChirpID.UUID
+	}
+	if action.TargetUserID.Valid {
+		formatted.TargetUserID = &action.TargetUserID.UUID
+	}
+	return formatted
+}
+
+// parseModerationLimit reads the limit query parameter, writing the error
+// response itself when it returns false.
+func parseModerationLimit(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return moderationDefaultLimit, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > moderationMaxLimit {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", moderationMaxLimit), err)
+		return 0, false
+	}
+	return int32(n), true
+}
+
+// listReportsHandler is the moderation queue, oldest first. It shows open
+// reports unless status is "resolved" or "all", and can be narrowed to a
+// reason or an assignee ("me", "none" or a user ID).
+func (cfg *apiConfig) listReportsHandler(w http.ResponseWriter, r *http.Request) {
+	caller, _ := principalFromContext(r.Context())
+	q := r.URL.Query()
+
+	status := q.Get("status")
+	switch status {
+	case "":
+		status = reportOpen
+	case "all":
+		status = ""
+	case reportOpen, reportResolved:
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be open, resolved or all", nil)
+		return
+	}
+
+	reason := q.Get("reason")
+	if reason != "" && !slices.Contains(reportReasons, reason) {
+		respondWithError(w, http.StatusBadRequest, "reason must be one of "+strings.Join(reportReasons, ", "), nil)
+		return
+	}
+
+	params := database.ListChirpReportsParams{
+		Status: status,
+		Reason: reason,
+	}
+	switch assignee := q.Get("assignee"); assignee {
+	case "":
+	case "me":
+		params.FilterAssignee = true
+		params.AssigneeID = uuid.NullUUID{UUID: caller.UserID, Valid: true}
+	case "none":
+		params.FilterAssignee = true
+	default:
+		assigneeID, err := uuid.Parse(assignee)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "assignee must be me, none or a user ID", err)
+			return
+		}
+		params.FilterAssignee = true
+		params.AssigneeID = uuid.NullUUID{UUID: assigneeID, Valid: true}
+	}
+
+	limit, ok := parseModerationLimit(w, r)
+	if !ok {
+		return
+	}
+	params.MaxResults = limit
+
+	reports, err := cfg.db.ListChirpReports(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching reports", err)
+		return
+	}
+
+	formatted := make([]ChirpReport, len(reports))
+	for i, report := range reports {
+		formatted[i] = chirpReportFromDB(report)
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+func (cfg *apiConfig) reportFromPath(w http.ResponseWriter, r *http.Request) (database.ChirpReport, bool) {
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return database.ChirpReport{}, false
+	}
+
+	report, err := cfg.db.GetChirpReport(r.Context(), reportID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Report not found", err)
+		return database.ChirpReport{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching report", err)
+		return database.ChirpReport{}, false
+	}
+	return report, true
+}
+
+// getReportHandler shows a report along with its audit trail.
+func (cfg *apiConfig) getReportHandler(w http.ResponseWriter, r *http.Request) {
+	report, ok := cfg.reportFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	actions, err := cfg.db.ListModerationActionsByReport(r.Context(), uuid.NullUUID{UUID: report.ID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching moderation actions", err)
+		return
+	}
+
+	formatted := chirpReportFromDB(report)
+	formatted.Actions = make([]ModerationAction, len(actions))
+	for i, action := range actions {
+		formatted.Actions[i] = moderationActionFromDB(action)
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+// assignReportHandler hands an open report to a moderator, or takes it
+// off everyone's plate when assignee_id is null.
+func (cfg *apiConfig) assignReportHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		AssigneeID *uuid.UUID `json:"assignee_id"`
+	}
+
+	caller, _ := principalFromContext(r.Context())
+	report, ok := cfg.reportFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if report.ResolvedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Report is already resolved", nil)
+		return
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	assignee := uuid.NullUUID{}
+	note := "unassigned"
+	if params.AssigneeID != nil {
+		user, err := cfg.db.GetUserByID(r.Context(), *params.AssigneeID)
+		if err == sql.ErrNoRows || (err == nil && !slices.Contains([]auth.Role{auth.RoleModerator, auth.RoleAdmin}, auth.Role(user.Role))) {
+			respondWithError(w, http.StatusBadRequest, "Reports can only be assigned to moderators", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error fetching assignee", err)
+			return
+		}
+		assignee = uuid.NullUUID{UUID: user.ID, Valid: true}
+		note = "assigned to " + user.ID.String()
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't assign report", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	report, err = qtx.AssignChirpReport(r.Context(), database.AssignChirpReportParams{
+		ID:         report.ID,
+		AssigneeID: assignee,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't assign report", err)
+		return
+	}
+
+	err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ID:          uuid.New(),
+		ModeratorID: uuid.NullUUID{UUID: caller.UserID, Valid: true},
+		Action:      moderationAssign,
+		ReportID:    uuid.NullUUID{UUID: report.ID, Valid: true},
+		ChirpID:     report.ChirpID,
+		Note:        note,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record moderation action", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't assign report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirpReportFromDB(report))
+}
+
+// resolveReportHandler takes action on a report. The action resolves every
+// open report of the same chirp, is recorded against each of them, and
+// their reporters are told the outcome.
+func (cfg *apiConfig) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action string `json:"action"`
+		// Note is shown to the author for warnings and suspensions.
+		Note string `json:"note"`
+		// SuspendDays of 0 suspends until the suspension is lifted.
+		SuspendDays int `json:"suspend_days"`
+	}
+
+	caller, _ := principalFromContext(r.Context())
+	report, ok := cfg.reportFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if report.ResolvedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Report is already resolved", nil)
+		return
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	params.Note = strings.TrimSpace(params.Note)
+
+	if !slices.Contains(resolutionActions, params.Action) {
+		respondWithError(w, http.StatusBadRequest, "action must be one of "+strings.Join(resolutionActions, ", "), nil)
+		return
+	}
+	if (params.Action == moderationHideChirp || params.Action == moderationDeleteChirp) && !report.ChirpID.Valid {
+		respondWithError(w, http.StatusConflict, "The reported chirp no longer exists", nil)
+		return
+	}
+	if (params.Action == moderationWarnAuthor || params.Action == moderationSuspendAuthor) && params.Note == "" {
+		respondWithError(w, http.StatusBadRequest, "A note for the author is required", nil)
+		return
+	}
+	if params.SuspendDays < 0 {
+		respondWithError(w, http.StatusBadRequest, "suspend_days can't be negative", nil)
+		return
+	}
+	if params.Action == moderationSuspendAuthor && !caller.hasRole(auth.RoleAdmin) {
+		// only admins can lift suspensions, so moderators can't suspend
+		// the people who would have to undo it
+		author, err := cfg.db.GetUserByID(r.Context(), report.AuthorID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error fetching author", err)
+			return
+		}
+		if role := auth.Role(author.Role); role == auth.RoleModerator || role == auth.RoleAdmin {
+			respondWithError(w, http.StatusForbidden, "Only admins can suspend moderators and admins", nil)
+			return
+		}
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't resolve report", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// resolve before acting so deleting the chirp doesn't detach its
+	// reports first
+	resolved, err := qtx.ResolveChirpReports(r.Context(), database.ResolveChirpReportsParams{
+		Resolution: params.Action,
+		ResolvedBy: caller.UserID,
+		ID:         report.ID,
+		ChirpID:    report.ChirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't resolve report", err)
+		return
+	}
+	if len(resolved) == 0 {
+		// another moderator got there first
+		respondWithError(w, http.StatusConflict, "Report is already resolved", nil)
+		return
+	}
+
+	for _, rep := range resolved {
+		err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+			ID:           uuid.New(),
+			ModeratorID:  uuid.NullUUID{UUID: caller.UserID, Valid: true},
+			Action:       params.Action,
+			ReportID:     uuid.NullUUID{UUID: rep.ID, Valid: true},
+			ChirpID:      rep.ChirpID,
+			TargetUserID: uuid.NullUUID{UUID: rep.AuthorID, Valid: true},
+			Note:         params.Note,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't record moderation action", err)
+			return
+		}
+	}
+
+	var suspension database.UserRestriction
+	switch params.Action {
+	case moderationHideChirp:
+		err = qtx.HideChirp(r.Context(), report.ChirpID.UUID)
+	case moderationDeleteChirp:
+		err = qtx.DeleteChirpByID(r.Context(), report.ChirpID.UUID)
+	case moderationSuspendAuthor:
+		expiresAt := sql.NullTime{}
+		if params.SuspendDays > 0 {
+			expiresAt = sql.NullTime{Time: time.Now().UTC().AddDate(0, 0, params.SuspendDays), Valid: true}
+		}
+		suspension, err = qtx.CreateUserRestriction(r.Context(), database.CreateUserRestrictionParams{
+			ID:        uuid.New(),
+			UserID:    report.AuthorID,
+			Kind:      restrictionSuspension,
+			Reason:    params.Note,
+			ExpiresAt: expiresAt,
+			CreatedBy: uuid.NullUUID{UUID: caller.UserID, Valid: true},
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply moderation action", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't resolve report", err)
+		return
+	}
+
+	switch params.Action {
+	case moderationDeleteChirp:
+		cfg.publishEvent(r.Context(), eventChirpDeleted, report.AuthorID, map[string]uuid.UUID{
+			"id":      report.ChirpID.UUID,
+			"user_id": report.AuthorID,
+		})
+	case moderationWarnAuthor:
+		cfg.notifyAuthor(r.Context(), report, "A moderator reviewed one of your chirps",
+			"A moderator reviewed this chirp of yours:\n\n%s\n\nTheir note: %s\n\nRepeated violations can lead to your account being suspended.\n",
+			report.ChirpBody, params.Note)
+	case moderationSuspendAuthor:
+		until := "until a moderator lifts it"
+		if suspension.ExpiresAt.Valid {
+			until = "until " + suspension.ExpiresAt.Time.Format(time.RFC1123)
+		}
+		cfg.notifyAuthor(r.Context(), report, "Your Chirpy account has been suspended",
+			"Your account has been suspended %s because of this chirp:\n\n%s\n\nReason: %s\n",
+			until, report.ChirpBody, params.Note)
+	}
+	cfg.notifyReporters(r.Context(), resolved, params.Action)
+
+	for _, rep := range resolved {
+		if rep.ID == report.ID {
+			report = rep
+		}
+	}
+	respondWithJSON(w, http.StatusOK, chirpReportFromDB(report))
+}
+
+func (cfg *apiConfig) notifyAuthor(ctx context.Context, report database.ChirpReport, subject, format string, args ...any) {
+	author, err := cfg.db.GetUserByID(ctx, report.AuthorID)
+	if err != nil {
+		log.Printf("Error loading author of reported chirp: %v", err)
+		return
+	}
+
+	err = cfg.mailer.Send(ctx, mail.Message{
+		To:      author.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf(format, args...),
+	})
+	if err != nil {
+		log.Printf("Error queueing moderation email: %v", err)
+	}
+}
+
+// notifyReporters tells everyone whose report was just resolved what came
+// of it, without naming the moderator or the exact action.
+func (cfg *apiConfig) notifyReporters(ctx context.Context, reports []database.ChirpReport, action string) {
+	outcome := "We took action on it. Thanks for helping keep Chirpy safe."
+	if action == moderationDismiss {
+		outcome = "We found that it doesn't break our rules, so we've left it up."
+	}
+
+	for _, report := range reports {
+		reporter, err := cfg.db.GetUserByID(ctx, report.ReporterID)
+		if err != nil {
+			log.Printf("Error loading reporter of report %s: %v", report.ID, err)
+			continue
+		}
+
+		err = cfg.mailer.Send(ctx, mail.Message{
+			To:      reporter.Email,
+			Subject: "We reviewed the chirp you reported",
+			Body: fmt.Sprintf(
+				"You reported this chirp on %s:\n\n%s\n\n%s\n",
+				report.CreatedAt.Format(time.RFC1123), report.ChirpBody, outcome,
+			),
+		})
+		if err != nil {
+			log.Printf("Error queueing report resolution email: %v", err)
+		}
+	}
+}
+
+// listModerationActionsHandler browses the audit trail, newest first,
+// optionally for one user_id or moderator_id.
+func (cfg *apiConfig) listModerationActionsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	params := database.ListModerationActionsParams{}
+
+	if raw := q.Get("user_id"); raw != "" {
+		userID, err := uuid.Parse(raw)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+			return
+		}
+		params.FilterTarget = true
+		params.TargetUserID = userID
+	}
+	if raw := q.Get("moderator_id"); raw != "" {
+		moderatorID, err := uuid.Parse(raw)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid moderator ID", err)
+			return
+		}
+		params.FilterModerator = true
+		params.ModeratorID = moderatorID
+	}
+
+	limit, ok := parseModerationLimit(w, r)
+	if !ok {
+		return
+	}
+	params.MaxResults = limit
+
+	actions, err := cfg.db.ListModerationActions(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching moderation actions", err)
+		return
+	}
+
+	formatted := make([]ModerationAction, len(actions))
+	for i, action := range actions {
+		formatted[i] = moderationActionFromDB(action)
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/mute"
+)
+
+// How feeds treat chirps that match the caller's mute rules.
+const (
+	mutedOmit     = "omit"
+	mutedCollapse = "collapse"
+)
+
+const muteRulesPerUser = 100
+
+type MuteRule struct {
+	ID          uuid.UUID  `json:"id"`
+	Keyword     string     `json:"keyword,omitempty"`
+	WholeWord   bool       `json:"whole_word"`
+	Regex       bool       `json:"regex"`
+	MutedUserID *uuid.UUID `json:"muted_user_id,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func muteRuleFromDB(rule database.MuteRule) MuteRule {
+	formatted := MuteRule{
+		ID:        rule.ID,
+		Keyword:   rule.Keyword.String,
+		WholeWord: rule.WholeWord,
+		Regex:     rule.Regex,
+		CreatedAt: rule.CreatedAt,
+	}
+	if rule.MutedUserID.Valid {
+		formatted.MutedUserID = &rule.MutedUserID.UUID
+	}
+	if rule.ExpiresAt.Valid {
+		formatted.ExpiresAt = &rule.ExpiresAt.Time
+	}
+	return formatted
+}
+
+// createMuteRuleHandler mutes either a keyword or an author, for
+// expires_in_seconds or until deleted when that is omitted.
+func (cfg *apiConfig) createMuteRuleHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Keyword          string     `json:"keyword"`
+		WholeWord        bool       `json:"whole_word"`
+		Regex            bool       `json:"regex"`
+		AuthorID         *uuid.UUID `json:"author_id"`
+		ExpiresInSeconds int        `json:"expires_in_seconds"`
+	}
+
+	caller, ok := cfg.requireScope(w, r, auth.ScopeProfileWrite)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if (params.Keyword == "") == (params.AuthorID == nil) {
+		respondWithError(w, http.StatusBadRequest, "Set either keyword or author_id", nil)
+		return
+	}
+	if params.ExpiresInSeconds < 0 {
+		respondWithError(w, http.StatusBadRequest, "expires_in_seconds can't be negative", nil)
+		return
+	}
+
+	createParams := database.CreateMuteRuleParams{
+		ID:     uuid.New(),
+		UserID: caller.UserID,
+	}
+	if params.ExpiresInSeconds > 0 {
+		createParams.ExpiresAt = sql.NullTime{Time: time.Now().UTC().Add(time.Duration(params.ExpiresInSeconds) * time.Second), Valid: true}
+	}
+
+	if params.AuthorID != nil {
+		if *params.AuthorID == caller.UserID {
+			respondWithError(w, http.StatusBadRequest, "You can't mute yourself", nil)
+			return
+		}
+		_, err := cfg.db.GetUserByID(r.Context(), *params.AuthorID)
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "User not found", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error fetching user", err)
+			return
+		}
+		createParams.MutedUserID = uuid.NullUUID{UUID: *params.AuthorID, Valid: true}
+	} else {
+		err := mute.Validate(params.Keyword, params.WholeWord, params.Regex)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid keyword: "+err.Error(), err)
+			return
+		}
+		createParams.Keyword = sql.NullString{String: params.Keyword, Valid: true}
+		createParams.WholeWord = params.WholeWord
+		createParams.Regex = params.Regex
+	}
+
+	// expired rules don't count towards the limit
+	err = cfg.db.DeleteExpiredMuteRules(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check mute rules", err)
+		return
+	}
+	existing, err := cfg.db.ListActiveMuteRules(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check mute rules", err)
+		return
+	}
+	if len(existing) >= muteRulesPerUser {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("You can have at most %d mute rules", muteRulesPerUser), nil)
+		return
+	}
+
+	rule, err := cfg.db.CreateMuteRule(r.Context(), createParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save mute rule", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, muteRuleFromDB(rule))
+}
+
+// listMuteRulesHandler lists the caller's rules that haven't expired.
+func (cfg *apiConfig) listMuteRulesHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.requireScope(w, r, auth.ScopeProfileWrite)
+	if !ok {
+		return
+	}
+
+	rules, err := cfg.db.ListActiveMuteRules(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching mute rules", err)
+		return
+	}
+
+	formatted := make([]MuteRule, len(rules))
+	for i, rule := range rules {
+		formatted[i] = muteRuleFromDB(rule)
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+func (cfg *apiConfig) deleteMuteRuleHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.requireScope(w, r, auth.ScopeProfileWrite)
+	if !ok {
+		return
+	}
+
+	muteID, err := uuid.Parse(r.PathValue("muteID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid mute rule ID", err)
+		return
+	}
+
+	rule, err := cfg.db.GetMuteRule(r.Context(), muteID)
+	if err == sql.ErrNoRows || (err == nil && rule.UserID != caller.UserID) {
+		respondWithError(w, http.StatusNotFound, "Mute rule not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching mute rule", err)
+		return
+	}
+
+	err = cfg.db.DeleteMuteRule(r.Context(), rule.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete mute rule", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyMutes drops the chirps in a feed that match the caller's mute
+// rules, or with mode "collapse" keeps them without their body. Anonymous
+// callers and the caller's own chirps are left alone.
+func (cfg *apiConfig) applyMutes(ctx context.Context, caller principal, chirps []Chirp, mode string) ([]Chirp, error) {
+	if !caller.viewer().Valid {
+		return chirps, nil
+	}
+
+	rules, err := cfg.db.ListActiveMuteRules(ctx, caller.UserID)
+	if err != nil || len(rules) == 0 {
+		return chirps, err
+	}
+
+	muteRules := make([]mute.Rule, len(rules))
+	for i, rule := range rules {
+		muteRules[i] = mute.Rule{
+			Keyword:   rule.Keyword.String,
+			WholeWord: rule.WholeWord,
+			Regex:     rule.Regex,
+			AuthorID:  rule.MutedUserID.UUID,
+		}
+	}
+	// a rule saved under older limits shouldn't take the whole feed down
+	// with it
+	filter, invalid := mute.Compile(muteRules)
+	for _, skipped := range invalid {
+		log.Printf("Skipping invalid mute rule %s: %v", rules[skipped.Index].ID, skipped.Err)
+	}
+
+	visible := make([]Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		if chirp.UserID == caller.UserID || !filter.Muted(chirp.UserID, chirp.Body) {
+			visible = append(visible, chirp)
+			continue
+		}
+		if mode == mutedCollapse {
+			chirp.Body = ""
+			chirp.Muted = true
+			visible = append(visible, chirp)
+		}
+	}
+	return visible, nil
+}
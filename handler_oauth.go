@@ -0,0 +1,833 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/oidc"
+)
+
+const (
+	oauthCodeTTL         = 10 * time.Minute
+	oauthAccessTokenTTL  = time.Hour
+	oauthRefreshTokenTTL = 90 * 24 * time.Hour
+)
+
+var errInvalidOAuthClient = errors.New("unknown client or bad client credentials")
+
+var errInvalidRedirectURI = errors.New("redirect_uri is not registered for this client")
+
+var errInvalidOAuthGrant = errors.New("authorization code or refresh token is invalid, expired or revoked")
+
+// scopeDescriptions is what the consent screen says each scope allows.
+var scopeDescriptions = map[auth.Scope]string{
+	auth.ScopeChirpsRead:   "Read chirps",
+	auth.ScopeChirpsWrite:  "Post and delete chirps as you",
+	auth.ScopeProfileWrite: "Change your profile",
+}
+
+type OAuthClient struct {
+	ID           uuid.UUID `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func oauthClientFromDB(client database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ID:           client.ID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Confidential: client.SecretHash.Valid,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// OAuthGrant is an app the user has allowed to act on their behalf.
+type OAuthGrant struct {
+	ClientID   uuid.UUID  `json:"client_id"`
+	ClientName string     `json:"client_name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func oauthGrantFromDB(grant database.ListOAuthGrantsByUserRow) OAuthGrant {
+	formatted := OAuthGrant{
+		ClientID:   grant.ClientID,
+		ClientName: grant.ClientName,
+		Scopes:     grant.Scopes,
+		CreatedAt:  grant.CreatedAt,
+	}
+	if grant.LastUsedAt.Valid {
+		formatted.LastUsedAt = &grant.LastUsedAt.Time
+	}
+	return formatted
+}
+
+// validateRedirectURI accepts https URLs, and plain http to the loopback
+// interface for native apps and local development.
+func validateRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" || u.Fragment != "" || strings.Contains(raw, "#") {
+		return errors.New("redirect URIs must be absolute URLs without a fragment")
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		switch u.Hostname() {
+		case "localhost", "127.0.0.1", "::1":
+			return nil
+		}
+	}
+	return errors.New("redirect URIs must use https")
+}
+
+func (cfg *apiConfig) createOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		// Confidential clients can keep a secret, so they get one and have
+		// to present it at the token endpoint.
+		Confidential bool `json:"confidential"`
+	}
+	type response struct {
+		OAuthClient
+		ClientSecret string `json:"client_secret,omitempty"`
+	}
+
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	if !p.EmailVerified {
+		respondWithError(w, http.StatusForbidden, "Verify your email before registering apps", nil)
+		return
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	params.Name = strings.TrimSpace(params.Name)
+	if len(params.Name) == 0 || len(params.Name) > 100 {
+		respondWithError(w, http.StatusBadRequest, "Name must be between 1 and 100 characters", nil)
+		return
+	}
+
+	if len(params.RedirectURIs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one redirect URI is required", nil)
+		return
+	}
+	for _, redirectURI := range params.RedirectURIs {
+		err := validateRedirectURI(redirectURI)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid redirect URI "+redirectURI+": "+err.Error(), err)
+			return
+		}
+	}
+
+	if len(params.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required", nil)
+		return
+	}
+	scopes, err := auth.ParseScopes(params.Scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	scopeNames := make([]string, len(scopes))
+	for i, scope := range scopes {
+		scopeNames[i] = string(scope)
+	}
+
+	secret := ""
+	secretHash := sql.NullString{}
+	if params.Confidential {
+		secret, err = auth.MakeOpaqueToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create client secret", err)
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := cfg.db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           uuid.New(),
+		OwnerID:      p.UserID,
+		Name:         params.Name,
+		SecretHash:   secretHash,
+		RedirectUris: params.RedirectURIs,
+		Scopes:       scopeNames,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save app", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		OAuthClient:  oauthClientFromDB(client),
+		ClientSecret: secret,
+	})
+}
+
+func (cfg *apiConfig) listOAuthClientsHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	clients, err := cfg.db.ListOAuthClientsByOwner(r.Context(), p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching apps", err)
+		return
+	}
+
+	formatted := make([]OAuthClient, len(clients))
+	for i, client := range clients {
+		formatted[i] = oauthClientFromDB(client)
+	}
+
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+func (cfg *apiConfig) deleteOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid client ID", err)
+		return
+	}
+
+	// grants, codes and refresh tokens go with the client
+	_, err = cfg.db.DeleteOAuthClient(r.Context(), database.DeleteOAuthClientParams{
+		ID:      clientID,
+		OwnerID: p.UserID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "App not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete app", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeRequest is a validated request to /oauth/authorize.
+type authorizeRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	Scopes        []auth.Scope
+	State         string
+	CodeChallenge string
+}
+
+// oauthRedirectError is an authorization error that is safe to report back
+// to the client through its redirect URI.
+type oauthRedirectError struct {
+	Code        string
+	Description string
+}
+
+func (e *oauthRedirectError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// parseAuthorizeRequest validates the parameters of an authorization
+// request. A bad client_id or redirect_uri must not be redirected to, so
+// those come back as errInvalidOAuthClient or errInvalidRedirectURI; any
+// other problem is an *oauthRedirectError alongside the client and
+// redirect URI to report it to.
+func (cfg *apiConfig) parseAuthorizeRequest(ctx context.Context, params url.Values) (authorizeRequest, error) {
+	clientID, err := uuid.Parse(params.Get("client_id"))
+	if err != nil {
+		return authorizeRequest{}, errInvalidOAuthClient
+	}
+	client, err := cfg.db.GetOAuthClient(ctx, clientID)
+	if err == sql.ErrNoRows {
+		return authorizeRequest{}, errInvalidOAuthClient
+	}
+	if err != nil {
+		return authorizeRequest{}, err
+	}
+
+	redirectURI := params.Get("redirect_uri")
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		return authorizeRequest{}, errInvalidRedirectURI
+	}
+
+	req := authorizeRequest{
+		Client:        client,
+		RedirectURI:   redirectURI,
+		State:         params.Get("state"),
+		CodeChallenge: params.Get("code_challenge"),
+	}
+
+	if params.Get("response_type") != "code" {
+		return req, &oauthRedirectError{"unsupported_response_type", "Only response_type=code is supported"}
+	}
+
+	names := strings.Fields(params.Get("scope"))
+	if len(names) == 0 {
+		names = client.Scopes
+	}
+	req.Scopes, err = auth.ParseScopes(names)
+	if err != nil {
+		return req, &oauthRedirectError{"invalid_scope", err.Error()}
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(client.Scopes, string(scope)) {
+			return req, &oauthRedirectError{"invalid_scope", "Scope " + string(scope) + " is not registered for this client"}
+		}
+	}
+
+	if req.CodeChallenge == "" || params.Get("code_challenge_method") != "S256" {
+		return req, &oauthRedirectError{"invalid_request", "PKCE with code_challenge_method=S256 is required"}
+	}
+
+	return req, nil
+}
+
+// authorizeRequestFromParams validates an authorization request, writing
+// the error response itself when it returns false.
+func (cfg *apiConfig) authorizeRequestFromParams(w http.ResponseWriter, r *http.Request, params url.Values) (authorizeRequest, bool) {
+	req, err := cfg.parseAuthorizeRequest(r.Context(), params)
+	var redirectErr *oauthRedirectError
+	switch {
+	case errors.As(err, &redirectErr):
+		redirectWithParams(w, r, req.RedirectURI, url.Values{
+			"error":             {redirectErr.Code},
+			"error_description": {redirectErr.Description},
+			"state":             {req.State},
+		})
+		return authorizeRequest{}, false
+	case errors.Is(err, errInvalidOAuthClient):
+		respondWithError(w, http.StatusBadRequest, "Unknown client_id", err)
+		return authorizeRequest{}, false
+	case errors.Is(err, errInvalidRedirectURI):
+		respondWithError(w, http.StatusBadRequest, "redirect_uri is not registered for this app", err)
+		return authorizeRequest{}, false
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check authorization request", err)
+		return authorizeRequest{}, false
+	}
+	return req, true
+}
+
+// redirectWithParams sends the user agent back to a client's redirect URI
+// with params added to its query, skipping empty values.
+func redirectWithParams(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Invalid redirect URI", err)
+		return
+	}
+	q := u.Query()
+	for name, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			q.Set(name, values[0])
+		}
+	}
+	u.RawQuery = q.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Authorize {{.ClientName}}</title>
+</head>
+<body>
+<h1>{{.ClientName}} wants to use your Chirpy account</h1>
+<p>If you allow it, {{.ClientName}} will be able to:</p>
+<ul>
+{{range .Scopes}}<li>{{.}}</li>
+{{end}}</ul>
+{{if .Error}}<p role="alert">{{.Error}}</p>
+{{end}}<form method="post" action="/oauth/authorize">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<label>Email <input type="email" name="email" autocomplete="username"></label>
+<label>Password <input type="password" name="password" autocomplete="current-password"></label>
+<button type="submit" name="action" value="approve">Allow</button>
+<button type="submit" name="action" value="deny">Deny</button>
+</form>
+</body>
+</html>
+`))
+
+// renderConsent shows the consent screen for req. errMsg is shown above
+// the form after a failed attempt.
+func renderConsent(w http.ResponseWriter, code int, req authorizeRequest, errMsg string) {
+	descriptions := make([]string, len(req.Scopes))
+	scopeNames := make([]string, len(req.Scopes))
+	for i, scope := range req.Scopes {
+		descriptions[i] = scopeDescriptions[scope]
+		scopeNames[i] = string(scope)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	// keep other sites from framing the page to trick users into approving
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(code)
+	err := consentTemplate.Execute(w, map[string]any{
+		"ClientName": req.Client.Name,
+		"Scopes":     descriptions,
+		"Error":      errMsg,
+		"Params": map[string]string{
+			"response_type":         "code",
+			"client_id":             req.Client.ID.String(),
+			"redirect_uri":          req.RedirectURI,
+			"scope":                 strings.Join(scopeNames, " "),
+			"state":                 req.State,
+			"code_challenge":        req.CodeChallenge,
+			"code_challenge_method": "S256",
+		},
+	})
+	if err != nil {
+		log.Printf("Error rendering consent page: %v", err)
+	}
+}
+
+func (cfg *apiConfig) oauthAuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := cfg.authorizeRequestFromParams(w, r, r.URL.Query())
+	if !ok {
+		return
+	}
+	renderConsent(w, http.StatusOK, req, "")
+}
+
+func (cfg *apiConfig) oauthApproveHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid form", err)
+		return
+	}
+
+	req, ok := cfg.authorizeRequestFromParams(w, r, r.PostForm)
+	if !ok {
+		return
+	}
+
+	if r.PostForm.Get("action") != "approve" {
+		redirectWithParams(w, r, req.RedirectURI, url.Values{
+			"error": {"access_denied"},
+			"state": {req.State},
+		})
+		return
+	}
+
+	user, ok := cfg.consentingUser(w, r, req)
+	if !ok {
+		return
+	}
+
+	scopeNames := make([]string, len(req.Scopes))
+	for i, scope := range req.Scopes {
+		scopeNames[i] = string(scope)
+	}
+
+	grant, err := cfg.db.UpsertOAuthGrant(r.Context(), database.UpsertOAuthGrantParams{
+		ID:       uuid.New(),
+		UserID:   user.ID,
+		ClientID: req.Client.ID,
+		Scopes:   scopeNames,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save authorization", err)
+		return
+	}
+
+	code, err := auth.MakeOpaqueToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create authorization code", err)
+		return
+	}
+
+	err = cfg.db.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		GrantID:       grant.ID,
+		RedirectUri:   req.RedirectURI,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().UTC().Add(oauthCodeTTL),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save authorization code", err)
+		return
+	}
+
+	redirectWithParams(w, r, req.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {req.State},
+	})
+}
+
+// consentingUser identifies who is approving an authorization request,
+// rendering the consent page again with an error when it returns false.
+// The app can send a login session from the last few minutes as a bearer
+// token instead of the email and password, which is how accounts created
+// through an identity provider approve since they have no password.
+func (cfg *apiConfig) consentingUser(w http.ResponseWriter, r *http.Request, req authorizeRequest) (database.User, bool) {
+	if r.Header.Get("Authorization") != "" {
+		caller, err := cfg.authenticate(r)
+		if err != nil || !caller.authenticatedWithin(reauthWindow) {
+			renderConsent(w, http.StatusUnauthorized, req, "Log in again to approve this app")
+			return database.User{}, false
+		}
+		user, err := cfg.db.GetUserByID(r.Context(), caller.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error fetching user", err)
+			return database.User{}, false
+		}
+		return user, true
+	}
+
+	email, err := auth.NormalizeEmail(r.PostForm.Get("email"))
+	if err != nil {
+		renderConsent(w, http.StatusUnauthorized, req, "Incorrect email or password")
+		return database.User{}, false
+	}
+
+	user, retryAfter, err := cfg.checkPassword(r.Context(), email, r.PostForm.Get("password"), clientIP(r))
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		renderConsent(w, http.StatusTooManyRequests, req, "Too many failed login attempts, try again later")
+		return database.User{}, false
+	}
+	if errors.Is(err, errIncorrectPassword) {
+		renderConsent(w, http.StatusUnauthorized, req, "Incorrect email or password")
+		return database.User{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check login attempts", err)
+		return database.User{}, false
+	}
+	if user.DeletedAt.Valid {
+		renderConsent(w, http.StatusForbidden, req, "This account is scheduled for deletion")
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// respondWithOAuthError writes a token endpoint error in the shape RFC 6749
+// requires, rather than the usual {"error": message}.
+func respondWithOAuthError(w http.ResponseWriter, code int, errCode, description string, err error) {
+	if err != nil {
+		log.Println(err)
+	}
+	type errorResponse struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, errorResponse{
+		Error:            errCode,
+		ErrorDescription: description,
+	})
+}
+
+// authenticateOAuthClient identifies the client calling the token endpoint
+// from HTTP Basic auth or the client_id and client_secret form fields.
+// Public clients only have to name themselves; PKCE proves the rest.
+func (cfg *apiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return database.OauthClient{}, errInvalidOAuthClient
+	}
+	client, err := cfg.db.GetOAuthClient(r.Context(), id)
+	if err == sql.ErrNoRows {
+		return database.OauthClient{}, errInvalidOAuthClient
+	}
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+
+	if client.SecretHash.Valid {
+		got := auth.HashToken(secret)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, errInvalidOAuthClient
+		}
+	}
+	return client, nil
+}
+
+// activeOAuthGrant loads a grant the client may still use, which it can't
+// while the user is suspended.
+func (cfg *apiConfig) activeOAuthGrant(ctx context.Context, client database.OauthClient, grantID uuid.UUID) (database.OauthGrant, error) {
+	grant, err := cfg.db.GetOAuthGrant(ctx, grantID)
+	if err == sql.ErrNoRows {
+		return database.OauthGrant{}, errInvalidOAuthGrant
+	}
+	if err != nil {
+		return database.OauthGrant{}, err
+	}
+	if grant.ClientID != client.ID {
+		return database.OauthGrant{}, errInvalidOAuthGrant
+	}
+
+	_, suspended, err := cfg.activeRestriction(ctx, grant.UserID, restrictionSuspension)
+	if err != nil {
+		return database.OauthGrant{}, err
+	}
+	if suspended {
+		return database.OauthGrant{}, errInvalidOAuthGrant
+	}
+	return grant, nil
+}
+
+func (cfg *apiConfig) redeemAuthorizationCode(ctx context.Context, client database.OauthClient, form url.Values) (database.OauthGrant, error) {
+	code, err := cfg.db.ConsumeOAuthAuthorizationCode(ctx, auth.HashToken(form.Get("code")))
+	if err == sql.ErrNoRows {
+		return database.OauthGrant{}, errInvalidOAuthGrant
+	}
+	if err != nil {
+		return database.OauthGrant{}, err
+	}
+
+	grant, err := cfg.activeOAuthGrant(ctx, client, code.GrantID)
+	if err != nil {
+		return database.OauthGrant{}, err
+	}
+
+	if form.Get("redirect_uri") != code.RedirectUri {
+		return database.OauthGrant{}, errInvalidOAuthGrant
+	}
+
+	verifier := form.Get("code_verifier")
+	challenge := oidc.PKCEChallenge(verifier)
+	if verifier == "" || subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1 {
+		return database.OauthGrant{}, errInvalidOAuthGrant
+	}
+
+	return grant, nil
+}
+
+func (cfg *apiConfig) redeemOAuthRefreshToken(ctx context.Context, client database.OauthClient, refreshToken string) (database.OauthGrant, error) {
+	// revoking as we read makes every refresh token single use
+	token, err := cfg.db.RevokeOAuthRefreshToken(ctx, auth.HashToken(refreshToken))
+	if err == sql.ErrNoRows {
+		return database.OauthGrant{}, cfg.checkOAuthRefreshTokenReuse(ctx, refreshToken)
+	}
+	if err != nil {
+		return database.OauthGrant{}, err
+	}
+
+	return cfg.activeOAuthGrant(ctx, client, token.GrantID)
+}
+
+// checkOAuthRefreshTokenReuse handles a refresh token that couldn't be
+// redeemed. One that was already used means two parties hold it, so the
+// whole grant is revoked and whichever of them is the app has to ask the
+// user again. It always returns errInvalidOAuthGrant unless that fails.
+func (cfg *apiConfig) checkOAuthRefreshTokenReuse(ctx context.Context, refreshToken string) error {
+	token, err := cfg.db.GetOAuthRefreshToken(ctx, auth.HashToken(refreshToken))
+	if err == sql.ErrNoRows || (err == nil && !token.RevokedAt.Valid) {
+		return errInvalidOAuthGrant
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("OAuth refresh token for grant %s was reused, revoking the grant", token.GrantID)
+	err = cfg.db.DeleteOAuthGrantByID(ctx, token.GrantID)
+	if err != nil {
+		return err
+	}
+	return errInvalidOAuthGrant
+}
+
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// issueOAuthTokens makes a scoped access token and a new refresh token for
+// grant.
+func (cfg *apiConfig) issueOAuthTokens(ctx context.Context, grant database.OauthGrant) (oauthTokenResponse, error) {
+	scopes, err := auth.ParseScopes(grant.Scopes)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	accessToken, err := auth.MakeDelegatedJWT(
+		grant.UserID,
+		cfg.jwtSecret,
+		oauthAccessTokenTTL,
+		grant.ClientID.String(),
+		grant.ID,
+		scopes,
+	)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	refreshToken, err := auth.MakeOpaqueToken()
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	err = cfg.db.CreateOAuthRefreshToken(ctx, database.CreateOAuthRefreshTokenParams{
+		TokenHash: auth.HashToken(refreshToken),
+		GrantID:   grant.ID,
+		ExpiresAt: time.Now().UTC().Add(oauthRefreshTokenTTL),
+	})
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	err = cfg.db.TouchOAuthGrant(ctx, grant.ID)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	return oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(grant.Scopes, " "),
+	}, nil
+}
+
+func (cfg *apiConfig) oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "Body must be form encoded", err)
+		return
+	}
+
+	client, err := cfg.authenticateOAuthClient(r)
+	if errors.Is(err, errInvalidOAuthClient) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed", err)
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+
+	var grant database.OauthGrant
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		grant, err = cfg.redeemAuthorizationCode(r.Context(), client, r.PostForm)
+	case "refresh_token":
+		grant, err = cfg.redeemOAuthRefreshToken(r.Context(), client, r.PostForm.Get("refresh_token"))
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Use authorization_code or refresh_token", nil)
+		return
+	}
+	if errors.Is(err, errInvalidOAuthGrant) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", err.Error(), err)
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+
+	tokens, err := cfg.issueOAuthTokens(r.Context(), grant)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+func (cfg *apiConfig) listOAuthGrantsHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	grants, err := cfg.db.ListOAuthGrantsByUser(r.Context(), p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching authorized apps", err)
+		return
+	}
+
+	formatted := make([]OAuthGrant, len(grants))
+	for i, grant := range grants {
+		formatted[i] = oauthGrantFromDB(grant)
+	}
+
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+func (cfg *apiConfig) revokeOAuthGrantHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid client ID", err)
+		return
+	}
+
+	// deleting the grant takes its codes and refresh tokens with it, and
+	// access tokens stop working straight away because authenticate checks
+	// the grant still exists
+	_, err = cfg.db.DeleteOAuthGrant(r.Context(), database.DeleteOAuthGrantParams{
+		ClientID: clientID,
+		UserID:   p.UserID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "App not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke app access", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/oidc"
+)
+
+// oidcLoginStateTTL is how long a user has to finish signing in at the
+// identity provider.
+const oidcLoginStateTTL = 10 * time.Minute
+
+// oidcStateCookie holds the state of the sign-in the browser started, so a
+// callback carrying someone else's state is refused. Otherwise an attacker
+// could send a victim the provider URL from their own attempt and have the
+// victim's identity linked to, or signed in as, the attacker's account.
+const oidcStateCookie = "chirpy_oidc_state"
+
+var errNoLinkedAccount = errors.New("no account is linked to this identity")
+
+var errIdentityLinkedElsewhere = errors.New("identity is linked to another account")
+
+var errRegistrationClosed = errors.New("registration is not open")
+
+// oidcProvider is an identity provider users can sign in with.
+type oidcProvider struct {
+	*oidc.Provider
+	Name string
+	// AutoProvision creates an account for a verified email that doesn't
+	// have one yet, instead of refusing the sign-in.
+	AutoProvision bool
+}
+
+type ExternalIdentity struct {
+	ID          uuid.UUID  `json:"id"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+func externalIdentityFromDB(identity database.ExternalIdentity) ExternalIdentity {
+	formatted := ExternalIdentity{
+		ID:        identity.ID,
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email.String,
+		CreatedAt: identity.CreatedAt,
+	}
+	if identity.LastLoginAt.Valid {
+		formatted.LastLoginAt = &identity.LastLoginAt.Time
+	}
+	return formatted
+}
+
+func (cfg *apiConfig) oidcProviderFromPath(w http.ResponseWriter, r *http.Request) (*oidcProvider, bool) {
+	provider, ok := cfg.oidcProviders[r.PathValue("provider")]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Unknown identity provider", nil)
+		return nil, false
+	}
+	return provider, true
+}
+
+// startOIDCLogin records a new login attempt, binds it to the browser with
+// a cookie and returns the provider URL to send the user to. linkUserID is
+// set when a signed-in user is adding the identity to their account rather
+// than logging in with it.
+func (cfg *apiConfig) startOIDCLogin(w http.ResponseWriter, r *http.Request, provider *oidcProvider, linkUserID uuid.NullUUID) (string, error) {
+	ctx := r.Context()
+	state, err := auth.MakeOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.NewPKCEVerifier()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.NewNonce()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", err
+	}
+
+	err = cfg.db.DeleteExpiredOIDCLoginStates(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	err = cfg.db.CreateOIDCLoginState(ctx, database.CreateOIDCLoginStateParams{
+		StateHash:    auth.HashToken(state),
+		Provider:     provider.Name,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().UTC().Add(oidcLoginStateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc/",
+		MaxAge:   int(oidcLoginStateTTL.Seconds()),
+		Secure:   cfg.platform != "dev",
+		HttpOnly: true,
+		// Lax, not Strict: the provider sends the browser back with a
+		// cross-site redirect
+		SameSite: http.SameSiteLaxMode,
+	})
+	return authURL, nil
+}
+
+func (cfg *apiConfig) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.oidcProviderFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	authURL, err := cfg.startOIDCLogin(w, r, provider, uuid.NullUUID{})
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Couldn't start sign-in with "+provider.Name, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (cfg *apiConfig) oidcLinkHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+
+	caller, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	provider, ok := cfg.oidcProviderFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	authURL, err := cfg.startOIDCLogin(w, r, provider, uuid.NullUUID{UUID: caller.UserID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Couldn't start sign-in with "+provider.Name, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{AuthorizationURL: authURL})
+}
+
+func (cfg *apiConfig) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.oidcProviderFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("error") != "" {
+		respondWithError(w, http.StatusUnauthorized, "Sign-in was not completed: "+q.Get("error"), nil)
+		return
+	}
+	if q.Get("code") == "" || q.Get("state") == "" {
+		respondWithError(w, http.StatusBadRequest, "code and state are required", nil)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(q.Get("state"))) != 1 {
+		respondWithError(w, http.StatusBadRequest, "Sign-in wasn't started from this browser", err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:   oidcStateCookie,
+		Path:   "/api/auth/oidc/",
+		MaxAge: -1,
+	})
+
+	loginState, err := cfg.db.ConsumeOIDCLoginState(r.Context(), database.ConsumeOIDCLoginStateParams{
+		StateHash: auth.HashToken(q.Get("state")),
+		Provider:  provider.Name,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusBadRequest, "Sign-in attempt is invalid or has expired", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check sign-in attempt", err)
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), q.Get("code"), loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't verify sign-in with "+provider.Name, err)
+		return
+	}
+
+	if loginState.LinkUserID.Valid {
+		identity, err := cfg.linkExternalIdentity(r.Context(), loginState.LinkUserID.UUID, provider, claims)
+		if errors.Is(err, errIdentityLinkedElsewhere) {
+			respondWithError(w, http.StatusConflict, "This identity is already linked to another account", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't link identity", err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, externalIdentityFromDB(identity))
+		return
+	}
+
+	user, err := cfg.resolveOIDCUser(r.Context(), provider, claims)
+	if errors.Is(err, errNoLinkedAccount) {
+		respondWithError(w, http.StatusForbidden, "No account is linked to this "+provider.Name+" identity", err)
+		return
+	}
+	if errors.Is(err, errRegistrationClosed) {
+		respondWithError(w, http.StatusForbidden, "No account is linked to this "+provider.Name+" identity and registration is closed", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't sign in", err)
+		return
+	}
+
+	cfg.completeLogin(w, r, user)
+}
+
+// resolveOIDCUser finds the account an external identity signs in to. An
+// unknown identity is linked to the account with the same email when both
+// sides have verified it, and otherwise gets a new account if the provider
+// auto-provisions and registration is open.
+func (cfg *apiConfig) resolveOIDCUser(ctx context.Context, provider *oidcProvider, claims oidc.Claims) (database.User, error) {
+	email := sql.NullString{}
+	if claims.EmailVerified {
+		normalized, err := auth.NormalizeEmail(claims.Email)
+		if err == nil {
+			email = sql.NullString{String: normalized, Valid: true}
+		}
+	}
+
+	identity, err := cfg.db.GetExternalIdentity(ctx, database.GetExternalIdentityParams{
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+	})
+	if err == nil {
+		err = cfg.db.TouchExternalIdentity(ctx, database.TouchExternalIdentityParams{
+			ID:    identity.ID,
+			Email: email,
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+		return cfg.db.GetUserByID(ctx, identity.UserID)
+	}
+	if err != sql.ErrNoRows {
+		return database.User{}, err
+	}
+
+	if !email.Valid {
+		return database.User{}, errNoLinkedAccount
+	}
+
+	user, err := cfg.db.GetUserByEmail(ctx, email.String)
+	if err == nil {
+		if !user.EmailVerifiedAt.Valid {
+			return database.User{}, errNoLinkedAccount
+		}
+		_, err = cfg.linkExternalIdentity(ctx, user.ID, provider, claims)
+		if err != nil {
+			return database.User{}, err
+		}
+		return user, nil
+	}
+	if err != sql.ErrNoRows {
+		return database.User{}, err
+	}
+
+	if !provider.AutoProvision {
+		return database.User{}, errNoLinkedAccount
+	}
+	// provisioning is a signup, so it follows REGISTRATION_MODE too
+	if cfg.registration != registrationOpen {
+		return database.User{}, errRegistrationClosed
+	}
+	return cfg.provisionOIDCUser(ctx, provider, claims, email.String)
+}
+
+// provisionOIDCUser creates an account for a new external identity. It has
+// no password; the user can set one later or keep signing in through the
+// provider.
+func (cfg *apiConfig) provisionOIDCUser(ctx context.Context, provider *oidcProvider, claims oidc.Claims, email string) (database.User, error) {
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	now := time.Now().UTC()
+	user, err := qtx.CreateUser(ctx, database.CreateUserParams{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          email,
+		HashedPassword: "",
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	user, err = qtx.MarkUserEmailVerified(ctx, database.MarkUserEmailVerifiedParams{
+		ID:    user.ID,
+		Email: email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	if claims.Name != "" {
+		user, err = qtx.UpdateUserProfile(ctx, database.UpdateUserProfileParams{
+			ID:          user.ID,
+			DisplayName: sql.NullString{String: claims.Name, Valid: true},
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+	}
+
+	_, err = qtx.CreateExternalIdentity(ctx, database.CreateExternalIdentityParams{
+		ID:       uuid.New(),
+		UserID:   user.ID,
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+		Email:    sql.NullString{String: email, Valid: true},
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = cfg.bootstrapAdmin(ctx)
+	if err != nil {
+		log.Printf("Error bootstrapping admin: %v", err)
+	}
+	return user, nil
+}
+
+func (cfg *apiConfig) linkExternalIdentity(ctx context.Context, userID uuid.UUID, provider *oidcProvider, claims oidc.Claims) (database.ExternalIdentity, error) {
+	existing, err := cfg.db.GetExternalIdentity(ctx, database.GetExternalIdentityParams{
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+	})
+	if err == nil {
+		if existing.UserID != userID {
+			return database.ExternalIdentity{}, errIdentityLinkedElsewhere
+		}
+		return existing, nil
+	}
+	if err != sql.ErrNoRows {
+		return database.ExternalIdentity{}, err
+	}
+
+	identity, err := cfg.db.CreateExternalIdentity(ctx, database.CreateExternalIdentityParams{
+		ID:       uuid.New(),
+		UserID:   userID,
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+		Email:    sql.NullString{String: claims.Email, Valid: claims.Email != ""},
+	})
+	if isUniqueViolation(err) {
+		return database.ExternalIdentity{}, errIdentityLinkedElsewhere
+	}
+	return identity, err
+}
+
+func (cfg *apiConfig) listIdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	identities, err := cfg.db.ListExternalIdentitiesByUserID(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching identities", err)
+		return
+	}
+
+	formatted := make([]ExternalIdentity, len(identities))
+	for i, identity := range identities {
+		formatted[i] = externalIdentityFromDB(identity)
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+func (cfg *apiConfig) unlinkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	identityID, err := uuid.Parse(r.PathValue("identityID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid identity ID", err)
+		return
+	}
+
+	_, err = cfg.db.DeleteExternalIdentity(r.Context(), database.DeleteExternalIdentityParams{
+		ID:     identityID,
+		UserID: caller.UserID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Identity not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlink identity", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/iamjoona/chippy/internal/database"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+)
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+
+// reservedHandles can't be claimed because they would be confused with
+// routes or staff accounts.
+var reservedHandles = map[string]bool{
+	"admin":   true,
+	"api":     true,
+	"app":     true,
+	"chirpy":  true,
+	"me":      true,
+	"root":    true,
+	"support": true,
+}
+
+var errHandleTaken = errors.New("handle is already taken")
+
+// PublicProfile is what anyone can see about a user. It deliberately
+// carries nothing from User that identifies or authenticates the account.
+type PublicProfile struct {
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func publicProfileFromDB(dbUser database.User) PublicProfile {
+	return PublicProfile{
+		Handle:      dbUser.Handle.String,
+		DisplayName: dbUser.DisplayName.String,
+		Bio:         dbUser.Bio.String,
+		AvatarURL:   dbUser.AvatarUrl.String,
+		CreatedAt:   dbUser.CreatedAt,
+	}
+}
+
+func validateHandle(handle string) error {
+	if !handlePattern.MatchString(handle) {
+		return errors.New("handle must be 3-30 letters, digits or underscores")
+	}
+	if reservedHandles[strings.ToLower(handle)] {
+		return errors.New("handle is reserved")
+	}
+	return nil
+}
+
+func validateAvatarURL(avatarURL string) error {
+	if len(avatarURL) > maxAvatarURLLength {
+		return errors.New("avatar_url is too long")
+	}
+	u, err := url.Parse(avatarURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("avatar_url must be an http or https URL")
+	}
+	return nil
+}
+
+// profileUpdate holds the optional profile fields of a PATCH request; nil
+// leaves a field unchanged and an empty string clears it.
+type profileUpdate struct {
+	Handle      *string `json:"handle"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+}
+
+func (u profileUpdate) isEmpty() bool {
+	return u.Handle == nil && u.DisplayName == nil && u.Bio == nil && u.AvatarURL == nil
+}
+
+func (u profileUpdate) validate() error {
+	if u.Handle != nil {
+		err := validateHandle(*u.Handle)
+		if err != nil {
+			return err
+		}
+	}
+	if u.DisplayName != nil && utf8.RuneCountInString(*u.DisplayName) > maxDisplayNameLength {
+		return errors.New("display_name is too long")
+	}
+	if u.Bio != nil && utf8.RuneCountInString(*u.Bio) > maxBioLength {
+		return errors.New("bio is too long")
+	}
+	if u.AvatarURL != nil && len(*u.AvatarURL) > 0 {
+		err := validateAvatarURL(*u.AvatarURL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply merges the update into dbUser's current profile.
+func (u profileUpdate) apply(dbUser database.User) database.UpdateUserProfileParams {
+	params := database.UpdateUserProfileParams{
+		ID:          dbUser.ID,
+		Handle:      dbUser.Handle,
+		DisplayName: dbUser.DisplayName,
+		Bio:         dbUser.Bio,
+		AvatarUrl:   dbUser.AvatarUrl,
+	}
+	if u.Handle != nil {
+		params.Handle = sql.NullString{String: *u.Handle, Valid: true}
+	}
+	if u.DisplayName != nil {
+		params.DisplayName = sql.NullString{String: strings.TrimSpace(*u.DisplayName), Valid: len(strings.TrimSpace(*u.DisplayName)) > 0}
+	}
+	if u.Bio != nil {
+		params.Bio = sql.NullString{String: strings.TrimSpace(*u.Bio), Valid: len(strings.TrimSpace(*u.Bio)) > 0}
+	}
+	if u.AvatarURL != nil {
+		params.AvatarUrl = sql.NullString{String: *u.AvatarURL, Valid: len(*u.AvatarURL) > 0}
+	}
+	return params
+}
+
+func (cfg *apiConfig) getProfileHandler(w http.ResponseWriter, r *http.Request) {
+	dbUser, err := cfg.db.GetUserByHandle(r.Context(), r.PathValue("handle"))
+	if err == sql.ErrNoRows || (err == nil && dbUser.DeletedAt.Valid) {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching user", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, publicProfileFromDB(dbUser))
+}
+
+func (cfg *apiConfig) handleAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Handle    string `json:"handle"`
+		Available bool   `json:"available"`
+		Reason    string `json:"reason,omitempty"`
+	}
+
+	handle := r.PathValue("handle")
+
+	err := validateHandle(handle)
+	if err != nil {
+		respondWithJSON(w, http.StatusOK, response{Handle: handle, Reason: err.Error()})
+		return
+	}
+
+	_, err = cfg.db.GetUserByHandle(r.Context(), handle)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusOK, response{Handle: handle, Available: true})
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking handle", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{Handle: handle, Reason: errHandleTaken.Error()})
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/iamjoona/chippy/internal/auth"
 )
@@ -24,10 +23,23 @@ func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(
+	if cfg.rejectSuspended(w, r, user.ID) {
+		return
+	}
+
+	session, err := cfg.db.GetRefreshToken(r.Context(), refreshToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't get session for refresh token", err)
+		return
+	}
+
+	// the session was authenticated when its refresh token was created,
+	// not now
+	accessToken, err := auth.MakeJWTWithAuthTime(
 		user.ID,
 		cfg.jwtSecret,
-		time.Hour,
+		accessTokenTTL,
+		session.CreatedAt,
 	)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Couldn't validate token", err)
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+)
+
+// reportReasons are the categories a chirp can be reported under.
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "self_harm", "misinformation", "other"}
+
+const reportDetailsMaxLength = 1000
+
+type ChirpReport struct {
+	ID         uuid.UUID          `json:"id"`
+	ChirpID    *uuid.UUID         `json:"chirp_id"`
+	ChirpBody  string             `json:"chirp_body"`
+	AuthorID   uuid.UUID          `json:"author_id"`
+	ReporterID uuid.UUID          `json:"reporter_id"`
+	Reason     string             `json:"reason"`
+	Details    string             `json:"details"`
+	Status     string             `json:"status"`
+	AssigneeID *uuid.UUID         `json:"assignee_id,omitempty"`
+	Resolution string             `json:"resolution,omitempty"`
+	ResolvedBy *uuid.UUID         `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time         `json:"resolved_at"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+	Actions    []ModerationAction `json:"actions,omitempty"`
+}
+
+func chirpReportFromDB(report database.ChirpReport) ChirpReport {
+	formatted := ChirpReport{
+		ID:         report.ID,
+		ChirpBody:  report.ChirpBody,
+		AuthorID:   report.AuthorID,
+		ReporterID: report.ReporterID,
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     reportOpen,
+		Resolution: report.Resolution.String,
+		CreatedAt:  report.CreatedAt,
+		UpdatedAt:  report.UpdatedAt,
+	}
+	if report.ChirpID.Valid {
+		formatted.ChirpID = &report.ChirpID.UUID
+	}
+	if report.AssigneeID.Valid {
+		formatted.AssigneeID = &report.AssigneeID.UUID
+	}
+	if report.ResolvedBy.Valid {
+		formatted.ResolvedBy = &report.ResolvedBy.UUID
+	}
+	if report.ResolvedAt.Valid {
+		formatted.Status = reportResolved
+		formatted.ResolvedAt = &report.ResolvedAt.Time
+	}
+	return formatted
+}
+
+// reportChirpHandler files a report against someone else's chirp. Each
+// user can report a chirp once.
+func (cfg *apiConfig) reportChirpHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	caller, ok := cfg.requireScope(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if !slices.Contains(reportReasons, params.Reason) {
+		respondWithError(w, http.StatusBadRequest, "reason must be one of "+strings.Join(reportReasons, ", "), nil)
+		return
+	}
+	params.Details = strings.TrimSpace(params.Details)
+	if len(params.Details) > reportDetailsMaxLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("details can be at most %d bytes", reportDetailsMaxLength), nil)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+		return
+	}
+
+	visible, err := cfg.chirpVisibleTo(r.Context(), chirp, caller.viewer())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+		return
+	}
+	if !visible {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		return
+	}
+
+	if chirp.UserID == caller.UserID {
+		respondWithError(w, http.StatusBadRequest, "You can't report your own chirp", nil)
+		return
+	}
+
+	report, err := cfg.db.CreateChirpReport(r.Context(), database.CreateChirpReportParams{
+		ID:         uuid.New(),
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpBody:  chirp.Body,
+		AuthorID:   chirp.UserID,
+		ReporterID: caller.UserID,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "You already reported this chirp", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save report", err)
+		return
+	}
+
+	// who is handling the report is for moderators only
+	formatted := chirpReportFromDB(report)
+	formatted.AssigneeID = nil
+	respondWithJSON(w, http.StatusCreated, formatted)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/mail"
+)
+
+// Audit trail actions for restrictions admins place directly.
+const (
+	moderationRestrictUser    = "restrict_user"
+	moderationLiftRestriction = "lift_restriction"
+)
+
+type UserRestriction struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Kind      string     `json:"kind"`
+	Reason    string     `json:"reason"`
+	Active    bool       `json:"active"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedBy *uuid.UUID `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	LiftedAt  *time.Time `json:"lifted_at"`
+}
+
+func userRestrictionFromDB(restriction database.UserRestriction) UserRestriction {
+	formatted := UserRestriction{
+		ID:        restriction.ID,
+		UserID:    restriction.UserID,
+		Kind:      restriction.Kind,
+		Reason:    restriction.Reason,
+		Active:    !restriction.LiftedAt.Valid && (!restriction.ExpiresAt.Valid || restriction.ExpiresAt.Time.After(time.Now().UTC())),
+		CreatedAt: restriction.CreatedAt,
+	}
+	if restriction.ExpiresAt.Valid {
+		formatted.ExpiresAt = &restriction.ExpiresAt.Time
+	}
+	if restriction.CreatedBy.Valid {
+		formatted.CreatedBy = &restriction.CreatedBy.UUID
+	}
+	if restriction.LiftedAt.Valid {
+		formatted.LiftedAt = &restriction.LiftedAt.Time
+	}
+	return formatted
+}
+
+// adminRestrictUserHandler suspends or shadow-bans a user, for
+// expires_in_seconds or until lifted when that is omitted.
+func (cfg *apiConfig) adminRestrictUserHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Kind             string `json:"kind"`
+		Reason           string `json:"reason"`
+		ExpiresInSeconds int    `json:"expires_in_seconds"`
+	}
+
+	caller, _ := principalFromContext(r.Context())
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	params.Reason = strings.TrimSpace(params.Reason)
+
+	if !slices.Contains(restrictionKinds, params.Kind) {
+		respondWithError(w, http.StatusBadRequest, "kind must be one of "+strings.Join(restrictionKinds, ", "), nil)
+		return
+	}
+	if params.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "A reason is required", nil)
+		return
+	}
+	if params.ExpiresInSeconds < 0 {
+		respondWithError(w, http.StatusBadRequest, "expires_in_seconds can't be negative", nil)
+		return
+	}
+
+	if userID == caller.UserID {
+		respondWithError(w, http.StatusBadRequest, "You can't restrict yourself", nil)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching user", err)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInSeconds > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().UTC().Add(time.Duration(params.ExpiresInSeconds) * time.Second), Valid: true}
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restrict user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	restriction, err := qtx.CreateUserRestriction(r.Context(), database.CreateUserRestrictionParams{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Kind:      params.Kind,
+		Reason:    params.Reason,
+		ExpiresAt: expiresAt,
+		CreatedBy: uuid.NullUUID{UUID: caller.UserID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restrict user", err)
+		return
+	}
+
+	err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ID:           uuid.New(),
+		ModeratorID:  uuid.NullUUID{UUID: caller.UserID, Valid: true},
+		Action:       moderationRestrictUser,
+		TargetUserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Note:         params.Kind + ": " + params.Reason,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record moderation action", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restrict user", err)
+		return
+	}
+
+	// a shadow ban only works if the user doesn't know about it
+	if restriction.Kind == restrictionSuspension {
+		until := "until an admin lifts it"
+		if restriction.ExpiresAt.Valid {
+			until = "until " + restriction.ExpiresAt.Time.Format(time.RFC1123)
+		}
+		err = cfg.mailer.Send(r.Context(), mail.Message{
+			To:      user.Email,
+			Subject: "Your Chirpy account has been suspended",
+			Body:    fmt.Sprintf("Your account has been suspended %s.\n\nReason: %s\n", until, restriction.Reason),
+		})
+		if err != nil {
+			log.Printf("Error queueing suspension email: %v", err)
+		}
+	}
+
+	respondWithJSON(w, http.StatusCreated, userRestrictionFromDB(restriction))
+}
+
+// adminListUserRestrictionsHandler shows every restriction a user has had,
+// newest first.
+func (cfg *apiConfig) adminListUserRestrictionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	restrictions, err := cfg.db.ListUserRestrictions(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching restrictions", err)
+		return
+	}
+
+	formatted := make([]UserRestriction, len(restrictions))
+	for i, restriction := range restrictions {
+		formatted[i] = userRestrictionFromDB(restriction)
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+// adminListActiveRestrictionsHandler lists restrictions in force, optionally
+// of one kind.
+func (cfg *apiConfig) adminListActiveRestrictionsHandler(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && !slices.Contains(restrictionKinds, kind) {
+		respondWithError(w, http.StatusBadRequest, "kind must be one of "+strings.Join(restrictionKinds, ", "), nil)
+		return
+	}
+
+	limit, ok := parseModerationLimit(w, r)
+	if !ok {
+		return
+	}
+
+	restrictions, err := cfg.db.ListActiveUserRestrictions(r.Context(), database.ListActiveUserRestrictionsParams{
+		Kind:       kind,
+		MaxResults: limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching restrictions", err)
+		return
+	}
+
+	formatted := make([]UserRestriction, len(restrictions))
+	for i, restriction := range restrictions {
+		formatted[i] = userRestrictionFromDB(restriction)
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+// adminLiftRestrictionHandler ends a restriction early.
+func (cfg *apiConfig) adminLiftRestrictionHandler(w http.ResponseWriter, r *http.Request) {
+	caller, _ := principalFromContext(r.Context())
+
+	restrictionID, err := uuid.Parse(r.PathValue("restrictionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid restriction ID", err)
+		return
+	}
+
+	restriction, err := cfg.db.GetUserRestriction(r.Context(), restrictionID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Restriction not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching restriction", err)
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't lift restriction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	restriction, err = qtx.LiftUserRestriction(r.Context(), restriction.ID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "Restriction was already lifted", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't lift restriction", err)
+		return
+	}
+
+	err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ID:           uuid.New(),
+		ModeratorID:  uuid.NullUUID{UUID: caller.UserID, Valid: true},
+		Action:       moderationLiftRestriction,
+		TargetUserID: uuid.NullUUID{UUID: restriction.UserID, Valid: true},
+		Note:         restriction.Kind,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record moderation action", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't lift restriction", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userRestrictionFromDB(restriction))
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/spam"
+)
+
+// Audit trail actions for chirps the spam rules held for review.
+const (
+	moderationApproveHeldChirp = "approve_held_chirp"
+	moderationRejectHeldChirp  = "reject_held_chirp"
+)
+
+// checkSpam scores a chirp userID is about to post.
+func (cfg *apiConfig) checkSpam(ctx context.Context, userID uuid.UUID, body string) (spam.Decision, error) {
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return spam.Decision{}, err
+	}
+
+	// chirps and users are stored as UTC in TIMESTAMP columns, which come
+	// back labelled UTC, so the windows have to be measured in UTC too
+	now := time.Now().UTC()
+	post := spam.Post{
+		Body:            body,
+		AuthorCreatedAt: user.CreatedAt,
+		Now:             now,
+	}
+
+	if lookback := cfg.spam.Lookback(); lookback > 0 {
+		recent, err := cfg.db.ListRecentChirpsByUserID(ctx, database.ListRecentChirpsByUserIDParams{
+			UserID:    userID,
+			CreatedAt: now.Add(-lookback),
+		})
+		if err != nil {
+			return spam.Decision{}, err
+		}
+		for _, chirp := range recent {
+			post.Recent = append(post.Recent, spam.Recent{Body: chirp.Body, CreatedAt: chirp.CreatedAt})
+		}
+	}
+
+	decision := cfg.spam.Evaluate(post)
+	if decision.Action != spam.Allow {
+		rules := make([]string, len(decision.Rules))
+		for i, rule := range decision.Rules {
+			rules[i] = rule.Rule
+		}
+		log.Printf("Spam check for user %s: %s (score %.2f from %s)", userID, decision.Action, decision.Score, strings.Join(rules, ", "))
+	}
+	return decision, nil
+}
+
+// respondToSpam turns away a chirp the spam rules didn't allow, or stores
+// it hidden for review, and reports whether it wrote a response.
+func (cfg *apiConfig) respondToSpam(w http.ResponseWriter, r *http.Request, decision spam.Decision, params database.CreateChirpParams) bool {
+	switch decision.Action {
+	case spam.Throttle:
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(cfg.spam.ThrottleFor.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "You're posting too quickly, try again later", nil)
+		return true
+	case spam.Reject:
+		respondWithError(w, http.StatusUnprocessableEntity, "This chirp looks like spam", nil)
+		return true
+	case spam.Hold:
+		cfg.holdChirp(w, r, decision, params)
+		return true
+	}
+	return false
+}
+
+// holdChirp saves a chirp hidden until a moderator approves it.
+func (cfg *apiConfig) holdChirp(w http.ResponseWriter, r *http.Request, decision spam.Decision, params database.CreateChirpParams) {
+	type response struct {
+		Chirp
+		HeldForReview bool `json:"held_for_review"`
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	dbChirp, err := qtx.CreateChirp(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		return
+	}
+
+	err = qtx.HideChirp(r.Context(), dbChirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		return
+	}
+
+	rules := make([]string, len(decision.Rules))
+	for i, rule := range decision.Rules {
+		rules[i] = rule.Rule
+	}
+	err = qtx.CreateSpamHold(r.Context(), database.CreateSpamHoldParams{
+		ChirpID: dbChirp.ID,
+		Score:   decision.Score,
+		Rules:   rules,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, response{
+		Chirp: Chirp{
+			ID:        dbChirp.ID,
+			Body:      dbChirp.Body,
+			CreatedAt: dbChirp.CreatedAt,
+			UpdatedAt: dbChirp.UpdatedAt,
+			UserID:    dbChirp.UserID,
+		},
+		HeldForReview: true,
+	})
+}
+
+type SpamHold struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+	Score     float64   `json:"score"`
+	Rules     []string  `json:"rules"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// listSpamHoldsHandler lists chirps waiting for review, oldest first.
+func (cfg *apiConfig) listSpamHoldsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, ok := parseModerationLimit(w, r)
+	if !ok {
+		return
+	}
+
+	holds, err := cfg.db.ListSpamHolds(r.Context(), limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching held chirps", err)
+		return
+	}
+
+	formatted := make([]SpamHold, len(holds))
+	for i, hold := range holds {
+		formatted[i] = SpamHold{
+			ChirpID:   hold.ChirpID,
+			Body:      hold.Body,
+			UserID:    hold.UserID,
+			Score:     hold.Score,
+			Rules:     hold.Rules,
+			CreatedAt: hold.CreatedAt,
+		}
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+func (cfg *apiConfig) spamHoldFromPath(w http.ResponseWriter, r *http.Request) (database.Chirp, bool) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return database.Chirp{}, false
+	}
+
+	_, err = cfg.db.GetSpamHold(r.Context(), chirpID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Held chirp not found", err)
+		return database.Chirp{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching held chirp", err)
+		return database.Chirp{}, false
+	}
+
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching held chirp", err)
+		return database.Chirp{}, false
+	}
+	return chirp, true
+}
+
+// approveSpamHoldHandler publishes a held chirp.
+func (cfg *apiConfig) approveSpamHoldHandler(w http.ResponseWriter, r *http.Request) {
+	caller, _ := principalFromContext(r.Context())
+	chirp, ok := cfg.spamHoldFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't approve chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.UnhideChirp(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't approve chirp", err)
+		return
+	}
+
+	err = qtx.DeleteSpamHold(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't approve chirp", err)
+		return
+	}
+
+	err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ID:           uuid.New(),
+		ModeratorID:  uuid.NullUUID{UUID: caller.UserID, Valid: true},
+		Action:       moderationApproveHeldChirp,
+		ChirpID:      uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record moderation action", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't approve chirp", err)
+		return
+	}
+
+	apiChirp := Chirp{
+		ID:        chirp.ID,
+		Body:      chirp.Body,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		UserID:    chirp.UserID,
+	}
+	cfg.publishEvent(r.Context(), eventChirpCreated, chirp.UserID, apiChirp)
+
+	respondWithJSON(w, http.StatusOK, apiChirp)
+}
+
+// rejectSpamHoldHandler deletes a held chirp.
+func (cfg *apiConfig) rejectSpamHoldHandler(w http.ResponseWriter, r *http.Request) {
+	caller, _ := principalFromContext(r.Context())
+	chirp, ok := cfg.spamHoldFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reject chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.DeleteChirpByID(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reject chirp", err)
+		return
+	}
+
+	err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ID:           uuid.New(),
+		ModeratorID:  uuid.NullUUID{UUID: caller.UserID, Valid: true},
+		Action:       moderationRejectHeldChirp,
+		ChirpID:      uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		Note:         chirp.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record moderation action", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reject chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// spamMetricsHandler reports how often each rule has fired and what the
+// pipeline decided since the server started.
+func (cfg *apiConfig) spamMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	type ruleConfig struct {
+		Name   string  `json:"name"`
+		Weight float64 `json:"weight"`
+		spam.RuleMetrics
+	}
+	type response struct {
+		Thresholds struct {
+			Throttle float64 `json:"throttle"`
+			Hold     float64 `json:"hold"`
+			Reject   float64 `json:"reject"`
+		} `json:"thresholds"`
+		Rules   []ruleConfig     `json:"rules"`
+		Actions map[string]int64 `json:"actions"`
+	}
+
+	metrics := cfg.spam.Metrics()
+	resp := response{
+		Rules:   make([]ruleConfig, len(cfg.spam.Rules)),
+		Actions: metrics.Actions,
+	}
+	resp.Thresholds.Throttle = cfg.spam.Thresholds.Throttle
+	resp.Thresholds.Hold = cfg.spam.Thresholds.Hold
+	resp.Thresholds.Reject = cfg.spam.Thresholds.Reject
+	for i, rule := range cfg.spam.Rules {
+		resp.Rules[i] = ruleConfig{
+			Name:        rule.Name(),
+			Weight:      rule.Weight,
+			RuleMetrics: metrics.Rules[rule.Name()],
+		}
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+)
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func personalAccessTokenFromDB(pat database.PersonalAccessToken) PersonalAccessToken {
+	formatted := PersonalAccessToken{
+		ID:        pat.ID,
+		Name:      pat.Name,
+		Scopes:    pat.Scopes,
+		CreatedAt: pat.CreatedAt,
+	}
+	if pat.ExpiresAt.Valid {
+		formatted.ExpiresAt = &pat.ExpiresAt.Time
+	}
+	if pat.LastUsedAt.Valid {
+		formatted.LastUsedAt = &pat.LastUsedAt.Time
+	}
+	return formatted
+}
+
+// requireSession only admits callers using a login session, so a personal
+// access token or an OAuth client can never mint or revoke other tokens.
+func (cfg *apiConfig) requireSession(w http.ResponseWriter, r *http.Request) (principal, bool) {
+	p, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return principal{}, false
+	}
+	if !p.isSession() {
+		respondWithError(w, http.StatusForbidden, "Only a login session can manage tokens", nil)
+		return principal{}, false
+	}
+	return p, true
+}
+
+func (cfg *apiConfig) createTokenHandler(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name             string   `json:"name"`
+		Scopes           []string `json:"scopes"`
+		ExpiresInSeconds int      `json:"expires_in_seconds"`
+	}
+	type response struct {
+		PersonalAccessToken
+		Token string `json:"token"`
+	}
+
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	if !p.EmailVerified {
+		respondWithError(w, http.StatusForbidden, "Verify your email before creating tokens", nil)
+		return
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if len(params.Name) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Name is required", nil)
+		return
+	}
+
+	if len(params.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required", nil)
+		return
+	}
+
+	scopes, err := auth.ParseScopes(params.Scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	if params.ExpiresInSeconds < 0 {
+		respondWithError(w, http.StatusBadRequest, "expires_in_seconds can't be negative", nil)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInSeconds > 0 {
+		expiresAt = sql.NullTime{
+			Time:  time.Now().UTC().Add(time.Duration(params.ExpiresInSeconds) * time.Second),
+			Valid: true,
+		}
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create token", err)
+		return
+	}
+
+	scopeNames := make([]string, len(scopes))
+	for i, scope := range scopes {
+		scopeNames[i] = string(scope)
+	}
+
+	pat, err := cfg.db.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		ID:        uuid.New(),
+		UserID:    p.UserID,
+		Name:      params.Name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    scopeNames,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save token", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		PersonalAccessToken: personalAccessTokenFromDB(pat),
+		Token:               token,
+	})
+}
+
+func (cfg *apiConfig) listTokensHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	pats, err := cfg.db.ListPersonalAccessTokensByUserID(r.Context(), p.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching tokens", err)
+		return
+	}
+
+	formatted := make([]PersonalAccessToken, len(pats))
+	for i, pat := range pats {
+		formatted[i] = personalAccessTokenFromDB(pat)
+	}
+
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+func (cfg *apiConfig) revokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := cfg.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid token ID", err)
+		return
+	}
+
+	_, err = cfg.db.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: p.UserID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Token not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke token", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+)
+
+// reauthWindow is how recently a session must have logged in to change its
+// email or password without sending current_password.
+const reauthWindow = 5 * time.Minute
+
+var errEmailTaken = errors.New("email is already in use")
+
+// checkNewPassword applies the password policy, writing the error response
+// itself when it returns false. personalInfo is passed on to
+// auth.PasswordPolicy.Check.
+func (cfg *apiConfig) checkNewPassword(w http.ResponseWriter, password string, personalInfo ...string) bool {
+	err := cfg.passwordPolicy.Check(password, personalInfo...)
+	var policyErr *auth.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		respondWithPasswordPolicyError(w, policyErr)
+		return false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error checking password", err)
+		return false
+	}
+	return true
+}
+
+// userUpdate is a change to the caller's own account. Fields left nil are
+// kept as they are.
+type userUpdate struct {
+	Email           *string `json:"email"`
+	Password        *string `json:"password"`
+	CurrentPassword string  `json:"current_password"`
+	profileUpdate
+}
+
+func (cfg *apiConfig) patchMeHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.requireScope(w, r, auth.ScopeProfileWrite)
+	if !ok {
+		return
+	}
+
+	params := userUpdate{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	cfg.updateMe(w, r, caller, params)
+}
+
+// updateMe applies params to the caller's account. Changing the email or
+// password needs a recent login or current_password; a new password also
+// signs out every session and returns a fresh pair for this one. Nothing is
+// changed unless every part of the update can be.
+func (cfg *apiConfig) updateMe(w http.ResponseWriter, r *http.Request, caller principal, params userUpdate) {
+	type response struct {
+		User
+		Token        string `json:"token,omitempty"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+	}
+
+	if params.Email != nil {
+		email, err := auth.NormalizeEmail(*params.Email)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid email address", err)
+			return
+		}
+		params.Email = &email
+	}
+
+	if params.Password != nil && len(*params.Password) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Password can't be empty", nil)
+		return
+	}
+
+	err := params.profileUpdate.validate()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching user", err)
+		return
+	}
+
+	changeEmail := params.Email != nil && *params.Email != dbUser.Email
+	changePassword := params.Password != nil
+
+	if changePassword {
+		personalInfo := []string{dbUser.Email, dbUser.Handle.String}
+		if params.Email != nil {
+			personalInfo = append(personalInfo, *params.Email)
+		}
+		if params.Handle != nil {
+			personalInfo = append(personalInfo, *params.Handle)
+		}
+		if !cfg.checkNewPassword(w, *params.Password, personalInfo...) {
+			return
+		}
+	}
+
+	if (changeEmail || changePassword) && !caller.authenticatedWithin(reauthWindow) {
+		if len(params.CurrentPassword) == 0 {
+			respondWithError(w, http.StatusUnauthorized, "current_password is required to change email or password", nil)
+			return
+		}
+		err = cfg.passwordHasher.Check(params.CurrentPassword, dbUser.HashedPassword)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Incorrect password", err)
+			return
+		}
+	}
+
+	var hashedPassword string
+	if changePassword {
+		hashedPassword, err = cfg.passwordHasher.Hash(*params.Password)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error hashing password", err)
+			return
+		}
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if changeEmail {
+		err = stageEmailChange(r.Context(), qtx, dbUser.ID, *params.Email)
+		if errors.Is(err, errEmailTaken) {
+			respondWithError(w, http.StatusConflict, "Email is already in use", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating email", err)
+			return
+		}
+	}
+
+	if !params.profileUpdate.isEmpty() {
+		_, err = qtx.UpdateUserProfile(r.Context(), params.profileUpdate.apply(dbUser))
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "Handle is already taken", errHandleTaken)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating profile", err)
+			return
+		}
+	}
+
+	if changePassword {
+		err = qtx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+			ID:             dbUser.ID,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error updating password", err)
+			return
+		}
+
+		// sign out every other session; this one gets a fresh pair below
+		err = qtx.RevokeAllRefreshTokensForUser(r.Context(), dbUser.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error revoking sessions", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating user", err)
+		return
+	}
+
+	resp := response{}
+	if changePassword {
+		resp.Token, resp.RefreshToken, err = cfg.issueSession(r.Context(), dbUser.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create session", err)
+			return
+		}
+	}
+
+	// the change is saved either way; the user can ask for another link
+	if changeEmail {
+		err = cfg.sendEmailVerification(r.Context(), dbUser.ID, *params.Email)
+		if err != nil {
+			log.Printf("Error sending verification email: %v", err)
+		}
+	}
+
+	dbUser, err = cfg.db.GetUserByID(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching user", err)
+		return
+	}
+
+	resp.User = userFromDB(dbUser)
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/webhook"
+)
+
+const (
+	webhookEndpointsPerUser     = 10
+	webhookDeliveriesMaxResults = 100
+)
+
+// webhookSecretPrefix makes endpoint secrets easy to recognise.
+const webhookSecretPrefix = "whsec_"
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID  `json:"id"`
+	URL                 string     `json:"url"`
+	EventTypes          []string   `json:"event_types"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+func webhookEndpointFromDB(endpoint database.WebhookEndpoint) WebhookEndpoint {
+	formatted := WebhookEndpoint{
+		ID:                  endpoint.ID,
+		URL:                 endpoint.Url,
+		EventTypes:          endpoint.EventTypes,
+		ConsecutiveFailures: endpoint.ConsecutiveFailures,
+		CreatedAt:           endpoint.CreatedAt,
+	}
+	if endpoint.DisabledAt.Valid {
+		formatted.DisabledAt = &endpoint.DisabledAt.Time
+	}
+	return formatted
+}
+
+type WebhookDelivery struct {
+	ID          uuid.UUID                `json:"id"`
+	EventID     uuid.UUID                `json:"event_id"`
+	EventType   string                   `json:"event_type"`
+	CreatedAt   time.Time                `json:"created_at"`
+	Attempts    int32                    `json:"attempts"`
+	DeliveredAt *time.Time               `json:"delivered_at"`
+	LastError   string                   `json:"last_error,omitempty"`
+	Payload     json.RawMessage          `json:"payload,omitempty"`
+	History     []WebhookDeliveryAttempt `json:"history,omitempty"`
+}
+
+func webhookDeliveryFromDB(delivery database.WebhookDelivery) WebhookDelivery {
+	formatted := WebhookDelivery{
+		ID:        delivery.ID,
+		EventID:   delivery.EventID,
+		EventType: delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Attempts:  delivery.Attempts,
+		LastError: delivery.LastError.String,
+	}
+	if delivery.DeliveredAt.Valid {
+		formatted.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	return formatted
+}
+
+type WebhookDeliveryAttempt struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  *int32    `json:"status_code"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int32     `json:"duration_ms"`
+}
+
+func webhookDeliveryAttemptFromDB(attempt database.WebhookDeliveryAttempt) WebhookDeliveryAttempt {
+	formatted := WebhookDeliveryAttempt{
+		AttemptedAt: attempt.AttemptedAt,
+		Error:       attempt.Error.String,
+		DurationMs:  attempt.DurationMs,
+	}
+	if attempt.StatusCode.Valid {
+		formatted.StatusCode = &attempt.StatusCode.Int32
+	}
+	return formatted
+}
+
+// webhookDeliveryFailed stands in for recorded delivery errors when they
+// are shown to an endpoint's owner, since connection errors describe
+// Chirpy's network rather than their endpoint.
+const webhookDeliveryFailed = "Delivery failed"
+
+// validateWebhookURL only accepts https endpoints on public addresses,
+// except in development where plain http and private addresses are
+// allowed for local receivers. Deliveries check the address again when
+// they connect, in case the host's DNS changes afterwards.
+func (cfg *apiConfig) validateWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("url must be an absolute URL")
+	}
+	if cfg.platform == "dev" {
+		if u.Scheme == "https" || u.Scheme == "http" {
+			return nil
+		}
+		return errors.New("url must use https")
+	}
+	if u.Scheme != "https" {
+		return errors.New("url must use https")
+	}
+
+	err = webhook.CheckHost(ctx, u.Hostname())
+	if errors.Is(err, webhook.ErrPrivateAddress) {
+		return errors.New("url must not point at a private address")
+	}
+	if err != nil {
+		return errors.New("url host can't be resolved")
+	}
+	return nil
+}
+
+// createWebhookEndpoint registers an endpoint for owner, or for every
+// user's events when owner is null.
+func (cfg *apiConfig) createWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	type parameters struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+	}
+	type response struct {
+		WebhookEndpoint
+		Secret string `json:"secret"`
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	err = cfg.validateWebhookURL(r.Context(), params.URL)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	if len(params.EventTypes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one event type is required", nil)
+		return
+	}
+	eventTypes := []string{}
+	for _, eventType := range params.EventTypes {
+		if !slices.Contains(outboundEventTypes, eventType) {
+			respondWithError(w, http.StatusBadRequest, "Unknown event type "+eventType, nil)
+			return
+		}
+		if !slices.Contains(eventTypes, eventType) {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+
+	if owner.Valid {
+		existing, err := cfg.db.ListWebhookEndpointsByOwner(r.Context(), owner)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check webhook endpoints", err)
+			return
+		}
+		if len(existing) >= webhookEndpointsPerUser {
+			respondWithError(w, http.StatusForbidden, fmt.Sprintf("You can have at most %d webhook endpoints", webhookEndpointsPerUser), nil)
+			return
+		}
+	}
+
+	secret, err := auth.MakeOpaqueToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create webhook secret", err)
+		return
+	}
+	secret = webhookSecretPrefix + secret
+
+	endpoint, err := cfg.db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		ID:         uuid.New(),
+		OwnerID:    owner,
+		Url:        params.URL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save webhook endpoint", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response{
+		WebhookEndpoint: webhookEndpointFromDB(endpoint),
+		Secret:          secret,
+	})
+}
+
+func (cfg *apiConfig) listWebhookEndpoints(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	var endpoints []database.WebhookEndpoint
+	var err error
+	if owner.Valid {
+		endpoints, err = cfg.db.ListWebhookEndpointsByOwner(r.Context(), owner)
+	} else {
+		endpoints, err = cfg.db.ListGlobalWebhookEndpoints(r.Context())
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching webhook endpoints", err)
+		return
+	}
+
+	formatted := make([]WebhookEndpoint, len(endpoints))
+	for i, endpoint := range endpoints {
+		formatted[i] = webhookEndpointFromDB(endpoint)
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+// webhookEndpointFromPath loads the endpoint named in the path if it
+// belongs to owner, writing the error response itself when it returns
+// false.
+func (cfg *apiConfig) webhookEndpointFromPath(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid endpoint ID", err)
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := cfg.db.GetWebhookEndpoint(r.Context(), endpointID)
+	if err == sql.ErrNoRows || (err == nil && endpoint.OwnerID != owner) {
+		respondWithError(w, http.StatusNotFound, "Webhook endpoint not found", err)
+		return database.WebhookEndpoint{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching webhook endpoint", err)
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+func (cfg *apiConfig) deleteWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoint, ok := cfg.webhookEndpointFromPath(w, r, owner)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.DeleteWebhookEndpoint(r.Context(), endpoint.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete webhook endpoint", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// enableWebhookEndpoint switches a disabled endpoint back on; deliveries
+// queued while it was off are sent on the next dispatch.
+func (cfg *apiConfig) enableWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoint, ok := cfg.webhookEndpointFromPath(w, r, owner)
+	if !ok {
+		return
+	}
+
+	endpoint, err := cfg.db.EnableWebhookEndpoint(r.Context(), endpoint.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't enable webhook endpoint", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhookEndpointFromDB(endpoint))
+}
+
+func (cfg *apiConfig) listWebhookDeliveries(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoint, ok := cfg.webhookEndpointFromPath(w, r, owner)
+	if !ok {
+		return
+	}
+
+	limit := webhookDeliveriesMaxResults
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > webhookDeliveriesMaxResults {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", webhookDeliveriesMaxResults), err)
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := cfg.db.ListWebhookDeliveriesByEndpoint(r.Context(), database.ListWebhookDeliveriesByEndpointParams{
+		EndpointID: endpoint.ID,
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching deliveries", err)
+		return
+	}
+
+	formatted := make([]WebhookDelivery, len(deliveries))
+	for i, delivery := range deliveries {
+		formatted[i] = webhookDeliveryFromDB(delivery)
+		if owner.Valid && formatted[i].LastError != "" {
+			formatted[i].LastError = webhookDeliveryFailed
+		}
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+// getWebhookDelivery shows one delivery with its payload and every attempt
+// made to send it.
+func (cfg *apiConfig) getWebhookDelivery(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoint, ok := cfg.webhookEndpointFromPath(w, r, owner)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid delivery ID", err)
+		return
+	}
+
+	delivery, err := cfg.db.GetWebhookDelivery(r.Context(), deliveryID)
+	if err == sql.ErrNoRows || (err == nil && delivery.EndpointID != endpoint.ID) {
+		respondWithError(w, http.StatusNotFound, "Delivery not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching delivery", err)
+		return
+	}
+
+	attempts, err := cfg.db.ListWebhookDeliveryAttempts(r.Context(), delivery.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching delivery attempts", err)
+		return
+	}
+
+	formatted := webhookDeliveryFromDB(delivery)
+	if owner.Valid && formatted.LastError != "" {
+		formatted.LastError = webhookDeliveryFailed
+	}
+	formatted.Payload = delivery.Payload
+	formatted.History = make([]WebhookDeliveryAttempt, len(attempts))
+	for i, attempt := range attempts {
+		formatted.History[i] = webhookDeliveryAttemptFromDB(attempt)
+		if owner.Valid && formatted.History[i].Error != "" {
+			formatted.History[i].Error = webhookDeliveryFailed
+		}
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+// requireWebhookOwner authenticates a user managing their own endpoints.
+func (cfg *apiConfig) requireWebhookOwner(w http.ResponseWriter, r *http.Request) (uuid.NullUUID, bool) {
+	caller, ok := cfg.requireSession(w, r)
+	if !ok {
+		return uuid.NullUUID{}, false
+	}
+	if !caller.EmailVerified {
+		respondWithError(w, http.StatusForbidden, "Verify your email before using webhooks", nil)
+		return uuid.NullUUID{}, false
+	}
+	return uuid.NullUUID{UUID: caller.UserID, Valid: true}, true
+}
+
+func (cfg *apiConfig) createWebhookEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	if owner, ok := cfg.requireWebhookOwner(w, r); ok {
+		cfg.createWebhookEndpoint(w, r, owner)
+	}
+}
+
+func (cfg *apiConfig) listWebhookEndpointsHandler(w http.ResponseWriter, r *http.Request) {
+	if owner, ok := cfg.requireWebhookOwner(w, r); ok {
+		cfg.listWebhookEndpoints(w, r, owner)
+	}
+}
+
+func (cfg *apiConfig) deleteWebhookEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	if owner, ok := cfg.requireWebhookOwner(w, r); ok {
+		cfg.deleteWebhookEndpoint(w, r, owner)
+	}
+}
+
+func (cfg *apiConfig) enableWebhookEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	if owner, ok := cfg.requireWebhookOwner(w, r); ok {
+		cfg.enableWebhookEndpoint(w, r, owner)
+	}
+}
+
+func (cfg *apiConfig) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	if owner, ok := cfg.requireWebhookOwner(w, r); ok {
+		cfg.listWebhookDeliveries(w, r, owner)
+	}
+}
+
+func (cfg *apiConfig) getWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	if owner, ok := cfg.requireWebhookOwner(w, r); ok {
+		cfg.getWebhookDelivery(w, r, owner)
+	}
+}
+
+// The admin handlers manage endpoints that receive every user's events.
+
+func (cfg *apiConfig) adminCreateWebhookEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.createWebhookEndpoint(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) adminListWebhookEndpointsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listWebhookEndpoints(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) adminDeleteWebhookEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.deleteWebhookEndpoint(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) adminEnableWebhookEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.enableWebhookEndpoint(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) adminListWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.listWebhookDeliveries(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) adminGetWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.getWebhookDelivery(w, r, uuid.NullUUID{})
+}
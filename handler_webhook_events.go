@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/database"
+)
+
+const webhookProviderPolka = "polka"
+
+// Outcomes of an inbound webhook event.
+const (
+	webhookReceived   = "received"
+	webhookProcessing = "processing"
+	webhookProcessed  = "processed"
+	webhookIgnored    = "ignored"
+	webhookFailed     = "failed"
+)
+
+const (
+	webhookEventsDefaultLimit = 50
+	webhookEventsMaxLimit     = 200
+)
+
+// webhookProcessingTimeout is how long an event can stay processing before
+// it is assumed the server handling it died and it is claimed again.
+const webhookProcessingTimeout = 5 * time.Minute
+
+// errWebhookEventHandled is returned for a redelivered event that has
+// already been processed.
+var errWebhookEventHandled = errors.New("webhook event has already been handled")
+
+// errWebhookEventInProgress is returned for an event another request is
+// processing right now.
+var errWebhookEventInProgress = errors.New("webhook event is being processed")
+
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Outcome     string          `json:"outcome"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int32           `json:"attempts"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+}
+
+func webhookEventFromDB(event database.WebhookEvent) WebhookEvent {
+	formatted := WebhookEvent{
+		ID:         event.ID,
+		Provider:   event.Provider,
+		EventID:    event.EventID,
+		EventType:  event.EventType,
+		ReceivedAt: event.ReceivedAt,
+		Outcome:    event.Outcome,
+		Error:      event.Error.String,
+		Attempts:   event.Attempts,
+		Payload:    event.Payload,
+	}
+	if event.ProcessedAt.Valid {
+		formatted.ProcessedAt = &event.ProcessedAt.Time
+	}
+	return formatted
+}
+
+// recordWebhookEvent stores an inbound event, or returns the stored copy if
+// the sender has delivered it before.
+func (cfg *apiConfig) recordWebhookEvent(ctx context.Context, params database.CreateWebhookEventParams) (database.WebhookEvent, error) {
+	event, err := cfg.db.CreateWebhookEvent(ctx, params)
+	if err == sql.ErrNoRows {
+		return cfg.db.GetWebhookEventByEventID(ctx, database.GetWebhookEventByEventIDParams{
+			Provider: params.Provider,
+			EventID:  params.EventID,
+		})
+	}
+	return event, err
+}
+
+// processWebhookEvent applies a stored event and records the outcome. Events
+// that were processed or ignored already are skipped with
+// errWebhookEventHandled unless replay is set, and events still being
+// processed with errWebhookEventInProgress, even when replaying, until
+// webhookProcessingTimeout has passed. The error from applying the event is
+// returned after the outcome has been saved.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, event database.WebhookEvent, replay bool) (database.WebhookEvent, error) {
+	claimed, err := cfg.db.ClaimWebhookEvent(ctx, database.ClaimWebhookEventParams{
+		ID:          event.ID,
+		StaleBefore: time.Now().UTC().Add(-webhookProcessingTimeout),
+		Replay:      replay,
+	})
+	if err == sql.ErrNoRows {
+		current, err := cfg.db.GetWebhookEvent(ctx, event.ID)
+		if err != nil {
+			return database.WebhookEvent{}, err
+		}
+		if current.Outcome == webhookProcessing {
+			return database.WebhookEvent{}, errWebhookEventInProgress
+		}
+		return database.WebhookEvent{}, errWebhookEventHandled
+	}
+	if err != nil {
+		return database.WebhookEvent{}, err
+	}
+	event = claimed
+
+	var outcome string
+	var applyErr error
+	switch event.Provider {
+	case webhookProviderPolka:
+		outcome, applyErr = cfg.applyPolkaEvent(ctx, event.Payload)
+	default:
+		outcome, applyErr = webhookFailed, fmt.Errorf("unknown webhook provider %q", event.Provider)
+	}
+
+	errText := sql.NullString{}
+	if applyErr != nil {
+		log.Printf("Error processing %s webhook %s: %v", event.Provider, event.EventID, applyErr)
+		errText = sql.NullString{String: applyErr.Error(), Valid: true}
+	}
+
+	event, err = cfg.db.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+		ID:      event.ID,
+		Outcome: outcome,
+		Error:   errText,
+	})
+	if err != nil {
+		return database.WebhookEvent{}, err
+	}
+	return event, applyErr
+}
+
+func (cfg *apiConfig) adminListWebhookEventsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit := webhookEventsDefaultLimit
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > webhookEventsMaxLimit {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", webhookEventsMaxLimit), err)
+			return
+		}
+		limit = n
+	}
+
+	events, err := cfg.db.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Provider:   q.Get("provider"),
+		EventType:  q.Get("event_type"),
+		Outcome:    q.Get("outcome"),
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching webhook events", err)
+		return
+	}
+
+	formatted := make([]WebhookEvent, len(events))
+	for i, event := range events {
+		formatted[i] = webhookEventFromDB(event)
+		// payloads are only shown when inspecting a single event
+		formatted[i].Payload = nil
+	}
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+func (cfg *apiConfig) webhookEventFromPath(w http.ResponseWriter, r *http.Request) (database.WebhookEvent, bool) {
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid event ID", err)
+		return database.WebhookEvent{}, false
+	}
+
+	event, err := cfg.db.GetWebhookEvent(r.Context(), eventID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusNotFound, "Webhook event not found", err)
+		return database.WebhookEvent{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching webhook event", err)
+		return database.WebhookEvent{}, false
+	}
+	return event, true
+}
+
+func (cfg *apiConfig) adminGetWebhookEventHandler(w http.ResponseWriter, r *http.Request) {
+	event, ok := cfg.webhookEventFromPath(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event))
+}
+
+func (cfg *apiConfig) adminReplayWebhookEventHandler(w http.ResponseWriter, r *http.Request) {
+	event, ok := cfg.webhookEventFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// a failure to apply the event is recorded on it and shows up in the
+	// response; only failing to save that is an error here
+	_, err := cfg.processWebhookEvent(r.Context(), event, true)
+	if errors.Is(err, errWebhookEventInProgress) {
+		respondWithError(w, http.StatusConflict, "Event is being processed, try again later", err)
+		return
+	}
+	if err != nil {
+		log.Printf("Replayed webhook event %s: %v", event.ID, err)
+	}
+
+	event, err = cfg.db.GetWebhookEvent(r.Context(), event.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching webhook event", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/iamjoona/chippy/internal/auth"
 	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/entitlements"
 )
 
 func (cfg *apiConfig) HandlerMetrics(w http.ResponseWriter, r *http.Request) {
@@ -34,6 +36,7 @@ func (cfg *apiConfig) HandlerMetrics(w http.ResponseWriter, r *http.Request) {
 */
 
 func (cfg *apiConfig) HandlerReset(w http.ResponseWriter, r *http.Request) {
+	// wiping every user is only ever allowed on dev, even for admins
 	if cfg.platform != "dev" {
 		http.Error(w, "Not allowed", http.StatusForbidden)
 		return
@@ -64,40 +67,113 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	email, err := auth.NormalizeEmail(params.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid email address", err)
+		return
+	}
+
+	// ADMIN_EMAIL can sign up until there is an admin, so a closed
+	// deployment can still get its first administrator. That doesn't let a
+	// stranger take the role: bootstrapAdmin waits for the address to be
+	// verified.
+	bootstrapping, err := cfg.awaitingBootstrapAdmin(r, email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error creating user", err)
+		return
+	}
+	if !bootstrapping {
+		switch {
+		case cfg.registration == registrationClosed:
+			respondWithError(w, http.StatusForbidden, "Registration is closed", nil)
+			return
+		case cfg.registration == registrationInviteOnly && len(params.InviteCode) == 0:
+			respondWithError(w, http.StatusForbidden, "An invite code is required to sign up", nil)
+			return
+		}
+	}
+
 	if len(params.Password) == 0 {
 		http.Error(w, "Password is required", http.StatusBadRequest)
 		return
 	}
 
+	if !cfg.checkNewPassword(w, params.Password, email) {
+		return
+	}
+
 	// hash password
-	hashedPassword, err := auth.HashPassword(params.Password)
+	hashedPassword, err := cfg.passwordHasher.Hash(params.Password)
 	if err != nil {
 		http.Error(w, "Error hashing password", http.StatusInternalServerError)
 		return
 	}
 
-	dbUser, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
-		Email:          params.Email,
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Error creating user", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// redeeming first means a failed signup gives the use back on rollback
+	var invite database.InviteCode
+	if len(params.InviteCode) > 0 {
+		invite, err = qtx.RedeemInviteCode(r.Context(), auth.NormalizeInviteCode(params.InviteCode))
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusForbidden, "Invite code is invalid, expired or used up", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error redeeming invite", err)
+			return
+		}
+	}
+
+	dbUser, err := qtx.CreateUser(r.Context(), database.CreateUserParams{
+		Email:          email,
 		HashedPassword: hashedPassword,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+		CreatedAt:      time.Now().UTC(),
+		UpdatedAt:      time.Now().UTC(),
 		ID:             uuid.New(),
 	})
 
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email is already in use", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error creating user", http.StatusInternalServerError)
+		return
+	}
+
+	if len(params.InviteCode) > 0 {
+		dbUser.InvitedBy = uuid.NullUUID{UUID: invite.CreatedBy, Valid: true}
+		dbUser.InviteCodeID = uuid.NullUUID{UUID: invite.ID, Valid: true}
+		err = qtx.SetUserInvite(r.Context(), database.SetUserInviteParams{
+			ID:           dbUser.ID,
+			InvitedBy:    dbUser.InvitedBy,
+			InviteCodeID: dbUser.InviteCodeID,
+		})
+		if err != nil {
+			http.Error(w, "Error creating user", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		http.Error(w, "Error creating user", http.StatusInternalServerError)
 		return
 	}
 
-	apiUser := User{
-		ID:          dbUser.ID,
-		Email:       dbUser.Email,
-		CreatedAt:   dbUser.CreatedAt,
-		UpdatedAt:   dbUser.UpdatedAt,
-		IsChirpyRed: false,
+	err = cfg.sendEmailVerification(r.Context(), dbUser.ID, dbUser.Email)
+	if err != nil {
+		log.Printf("Error sending verification email: %v", err)
 	}
 
-	respondWithJSON(w, http.StatusCreated, apiUser)
+	respondWithJSON(w, http.StatusCreated, userFromDB(dbUser))
 }
 
 func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request) {
@@ -115,31 +191,47 @@ func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// validate chirp
+	bodyLength := len(params.Body)
 	cleanedBody, profane := checkProfanity(params.Body)
 	if profane {
 		params.Body = cleanedBody
 	}
 
-	// check JWT
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	// check token
+	caller, ok := cfg.requireScope(w, r, auth.ScopeChirpsWrite)
+	if !ok {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	if !caller.EmailVerified {
+		respondWithError(w, http.StatusForbidden, "Verify your email before posting", nil)
 		return
 	}
 
-	dbChirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
+	maxLength := cfg.entitlementsOf(caller).Limit(entitlements.LimitChirpLength)
+	if int64(bodyLength) > maxLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Chirp is too long, your plan allows %d bytes", maxLength), nil)
+		return
+	}
+
+	chirpParams := database.CreateChirpParams{
 		Body:      params.Body,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
 		ID:        uuid.New(),
-		UserID:    userID,
-	})
+		UserID:    caller.UserID,
+	}
+
+	decision, err := cfg.checkSpam(r.Context(), caller.UserID, params.Body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check chirp", err)
+		return
+	}
+	if cfg.respondToSpam(w, r, decision, chirpParams) {
+		return
+	}
+
+	dbChirp, err := cfg.db.CreateChirp(r.Context(), chirpParams)
 
 	if err != nil {
 		http.Error(w, "Error creating chirp", http.StatusInternalServerError)
@@ -154,6 +246,8 @@ func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request)
 		UserID:    dbChirp.UserID,
 	}
 
+	cfg.publishEvent(r.Context(), eventChirpCreated, caller.UserID, apiChirp)
+
 	respondWithJSON(w, http.StatusCreated, apiChirp)
 }
 
@@ -161,11 +255,22 @@ func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
 	authorId := r.URL.Query().Get("author_id")
 	sortOrder := r.URL.Query().Get("sort")
 
+	caller, ok := cfg.optionalScope(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
 	if sortOrder != "" && sortOrder != "asc" && sortOrder != "desc" {
 		respondWithError(w, http.StatusBadRequest, "Sort parameter must be 'asc' or 'desc'", nil)
 		return
 	}
 
+	mutedMode := r.URL.Query().Get("muted")
+	if mutedMode != "" && mutedMode != mutedOmit && mutedMode != mutedCollapse {
+		respondWithError(w, http.StatusBadRequest, "Muted parameter must be 'omit' or 'collapse'", nil)
+		return
+	}
+
 	// If we have an author ID, get their chirps
 	if authorId != "" {
 		parsedAuthorId, err := uuid.Parse(authorId)
@@ -176,9 +281,15 @@ func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
 
 		var authorChirps []database.Chirp
 		if sortOrder == "desc" {
-			authorChirps, err = cfg.db.GetChirpsByUserIDDesc(r.Context(), parsedAuthorId)
+			authorChirps, err = cfg.db.GetChirpsByUserIDDesc(r.Context(), database.GetChirpsByUserIDDescParams{
+				UserID:   parsedAuthorId,
+				ViewerID: caller.viewer(),
+			})
 		} else {
-			authorChirps, err = cfg.db.GetChirpsByUserID(r.Context(), parsedAuthorId)
+			authorChirps, err = cfg.db.GetChirpsByUserID(r.Context(), database.GetChirpsByUserIDParams{
+				UserID:   parsedAuthorId,
+				ViewerID: caller.viewer(),
+			})
 		}
 
 		if err != nil {
@@ -197,6 +308,12 @@ func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		formattedChirps, err = cfg.applyMutes(r.Context(), caller, formattedChirps, mutedMode)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error applying mute rules", err)
+			return
+		}
+
 		respondWithJSON(w, http.StatusOK, formattedChirps)
 		return
 	}
@@ -205,9 +322,9 @@ func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
 	var chirps []database.Chirp
 	var err error
 	if sortOrder == "desc" {
-		chirps, err = cfg.db.GetAllChirpsDesc(r.Context())
+		chirps, err = cfg.db.GetAllChirpsDesc(r.Context(), caller.viewer())
 	} else {
-		chirps, err = cfg.db.GetAllChirps(r.Context())
+		chirps, err = cfg.db.GetAllChirps(r.Context(), caller.viewer())
 	}
 
 	if err != nil {
@@ -226,10 +343,21 @@ func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	formattedChirps, err = cfg.applyMutes(r.Context(), caller, formattedChirps, mutedMode)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error applying mute rules", err)
+		return
+	}
+
 	respondWithJSON(w, http.StatusOK, formattedChirps)
 }
 
 func (cfg *apiConfig) getSingleChirpHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := cfg.optionalScope(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
 	chirpID := r.PathValue("chirpID")
 	if len(chirpID) == 0 {
 		http.Error(w, "Chirp ID is required", http.StatusNotFound)
@@ -250,6 +378,16 @@ func (cfg *apiConfig) getSingleChirpHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	visible, err := cfg.chirpVisibleTo(r.Context(), chirp, caller.viewer())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error fetching chirp", err)
+		return
+	}
+	if !visible {
+		http.Error(w, "Error fetching chirp", http.StatusNotFound)
+		return
+	}
+
 	formattedChirp := Chirp{
 		ID:        chirp.ID,
 		Body:      chirp.Body,
@@ -261,33 +399,23 @@ func (cfg *apiConfig) getSingleChirpHandler(w http.ResponseWriter, r *http.Reque
 	respondWithJSON(w, http.StatusOK, formattedChirp)
 }
 
+// updateUserHandler replaces the caller's email and password. It goes
+// through the same checks as PATCH /api/users/me.
 func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
-	// Add debug logging
-	log.Printf("Debug - Auth header: %s", r.Header.Get("Authorization"))
-
 	// Get and validate token
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Debug - Token error: %v", err)
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
-		return
-	}
-
-	// Get user ID from token
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		log.Printf("Debug - JWT validation error: %v", err)
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+	caller, ok := cfg.requireScope(w, r, auth.ScopeProfileWrite)
+	if !ok {
 		return
 	}
 
 	// Decode request body
 	decoder := json.NewDecoder(r.Body)
 	params := struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email           string `json:"email"`
+		Password        string `json:"password"`
+		CurrentPassword string `json:"current_password"`
 	}{}
-	err = decoder.Decode(&params)
+	err := decoder.Decode(&params)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
 		return
@@ -299,48 +427,17 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Hash new password
-	hashedPassword, err := auth.HashPassword(params.Password)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error hashing password", err)
-		return
-	}
-
-	// Update user
-	dbUser, err := cfg.db.UpdateUser(r.Context(), database.UpdateUserParams{
-		ID:             userID,
-		Email:          params.Email,
-		HashedPassword: hashedPassword,
-		UpdatedAt:      time.Now(),
-	})
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error updating user", err)
-		return
-	}
-
-	// Return updated user
-	respondWithJSON(w, http.StatusOK, User{
-		ID:        dbUser.ID,
-		Email:     dbUser.Email,
-		CreatedAt: dbUser.CreatedAt,
-		UpdatedAt: dbUser.UpdatedAt,
+	cfg.updateMe(w, r, caller, userUpdate{
+		Email:           &params.Email,
+		Password:        &params.Password,
+		CurrentPassword: params.CurrentPassword,
 	})
 }
 
 func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request) {
 	// Get and validate token
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Debug - Token error: %v", err)
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
-		return
-	}
-
-	// Get user ID from token
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		log.Printf("Debug - JWT validation error: %v", err)
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+	caller, ok := cfg.requireScope(w, r, auth.ScopeChirpsWrite)
+	if !ok {
 		return
 	}
 
@@ -363,7 +460,7 @@ func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if chirp.UserID != userID {
+	if chirp.UserID != caller.UserID {
 		http.Error(w, "User does not own chirp", http.StatusForbidden)
 		return
 	}
@@ -375,5 +472,10 @@ func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	cfg.publishEvent(r.Context(), eventChirpDeleted, caller.UserID, map[string]uuid.UUID{
+		"id":      chirp.ID,
+		"user_id": chirp.UserID,
+	})
+
 	respondWithJSON(w, http.StatusNoContent, nil)
 }
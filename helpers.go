@@ -2,8 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/lib/pq"
 )
 
 func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
@@ -32,3 +36,23 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.WriteHeader(code)
 	w.Write(dat)
 }
+
+// respondWithPasswordPolicyError lists every rule a rejected password broke
+// alongside the usual error message.
+func respondWithPasswordPolicyError(w http.ResponseWriter, err *auth.PasswordPolicyError) {
+	type errorResponse struct {
+		Error      string                       `json:"error"`
+		Violations []auth.PasswordRuleViolation `json:"violations"`
+	}
+	respondWithJSON(w, http.StatusBadRequest, errorResponse{
+		Error:      "Password doesn't meet the password policy",
+		Violations: err.Violations,
+	})
+}
+
+// isUniqueViolation reports whether err is postgres rejecting a duplicate
+// value for a UNIQUE constraint.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"encoding/base32"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -11,7 +12,6 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type TokenType string
@@ -24,18 +24,33 @@ const (
 // ErrNoAuthHeaderIncluded -
 var ErrNoAuthHeaderIncluded = errors.New("no auth header included in request")
 
-// HashPassword -
+// HashPassword hashes with DefaultPasswordHasher
 func HashPassword(password string) (string, error) {
-	dat, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(dat), nil
+	return DefaultPasswordHasher.Hash(password)
 }
 
-// CheckPasswordHash -
+// CheckPasswordHash accepts hashes from any supported algorithm
 func CheckPasswordHash(password, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return DefaultPasswordHasher.Check(password, hash)
+}
+
+// AccessClaims are the claims carried by chirpy access tokens.
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	// AuthTime is when the user last proved who they are, as opposed to
+	// IssuedAt which moves forward every time the token is refreshed.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// The rest are only set on tokens issued to OAuth clients.
+	ClientID string `json:"client_id,omitempty"`
+	GrantID  string `json:"grant_id,omitempty"`
+	// Scope is space separated, as in OAuth.
+	Scope string `json:"scope,omitempty"`
+}
+
+// IsDelegated reports whether the token was issued to an OAuth client
+// rather than to the user's own session.
+func (c AccessClaims) IsDelegated() bool {
+	return c.GrantID != ""
 }
 
 // MakeJWT -
@@ -43,47 +58,99 @@ func MakeJWT(
 	userID uuid.UUID,
 	tokenSecret string,
 	expiresIn time.Duration,
+) (string, error) {
+	return MakeJWTWithAuthTime(userID, tokenSecret, expiresIn, time.Now().UTC())
+}
+
+// MakeJWTWithAuthTime makes an access token for a session that was
+// authenticated at authTime.
+func MakeJWTWithAuthTime(
+	userID uuid.UUID,
+	tokenSecret string,
+	expiresIn time.Duration,
+	authTime time.Time,
 ) (string, error) {
 	signingKey := []byte(tokenSecret)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		AuthTime: jwt.NewNumericDate(authTime.UTC()),
+	})
+	return token.SignedString(signingKey)
+}
+
+// MakeDelegatedJWT makes an access token an OAuth client uses on the
+// user's behalf, limited to scopes and valid only while grantID stands.
+func MakeDelegatedJWT(
+	userID uuid.UUID,
+	tokenSecret string,
+	expiresIn time.Duration,
+	clientID string,
+	grantID uuid.UUID,
+	scopes []Scope,
+) (string, error) {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+
+	signingKey := []byte(tokenSecret)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		ClientID: clientID,
+		GrantID:  grantID.String(),
+		Scope:    strings.Join(names, " "),
 	})
 	return token.SignedString(signingKey)
 }
 
 // ValidateJWT -
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
+	userID, _, err := ParseAccessToken(tokenString, tokenSecret)
+	return userID, err
+}
+
+// ParseAccessToken validates an access token and returns the user it was
+// issued to along with its claims.
+func ParseAccessToken(tokenString, tokenSecret string) (uuid.UUID, AccessClaims, error) {
+	claimsStruct := AccessClaims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&claimsStruct,
 		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
 	)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, AccessClaims{}, err
 	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, AccessClaims{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, AccessClaims{}, err
 	}
 	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+		return uuid.Nil, AccessClaims{}, errors.New("invalid issuer")
 	}
 
 	id, err := uuid.Parse(userIDString)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+		return uuid.Nil, AccessClaims{}, fmt.Errorf("invalid user ID: %w", err)
 	}
-	return id, nil
+	return id, claimsStruct, nil
 }
 
 // GetBearerToken -
@@ -103,6 +170,12 @@ func GetBearerToken(headers http.Header) (string, error) {
 // MakeRefreshToken makes a random 256 bit token
 // encoded in hex
 func MakeRefreshToken() (string, error) {
+	return MakeOpaqueToken()
+}
+
+// MakeOpaqueToken makes a random 256 bit token
+// encoded in hex, for single-use links and other bearer secrets
+func MakeOpaqueToken() (string, error) {
 	token := make([]byte, 32)
 	_, err := rand.Read(token)
 	if err != nil {
@@ -111,6 +184,23 @@ func MakeRefreshToken() (string, error) {
 	return hex.EncodeToString(token), nil
 }
 
+// MakeInviteCode makes a random 80 bit code that is easy to read out or
+// type, formatted as XXXX-XXXX-XXXX-XXXX.
+func MakeInviteCode() (string, error) {
+	raw := make([]byte, 10)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	encoded := base32.StdEncoding.EncodeToString(raw)
+	return encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16], nil
+}
+
+// NormalizeInviteCode undoes the ways people mangle a code when copying it.
+func NormalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if authHeader == "" {
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -103,3 +105,339 @@ func TestValidateJWT(t *testing.T) {
 		})
 	}
 }
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:   "Known scopes",
+			scopes: []string{"chirps:read", "chirps:write"},
+			want:   2,
+		},
+		{
+			name:   "Duplicates are dropped",
+			scopes: []string{"chirps:read", "chirps:read"},
+			want:   1,
+		},
+		{
+			name:    "Unknown scope",
+			scopes:  []string{"chirps:read", "admin"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScopes(tt.scopes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseScopes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != tt.want {
+				t.Errorf("ParseScopes() got %d scopes, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffPolicyDelay(t *testing.T) {
+	policy := BackoffPolicy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+	}
+
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{name: "No failures", failures: 0, want: 0},
+		{name: "Within free attempts", failures: 3, want: 0},
+		{name: "First lockout", failures: 4, want: time.Second},
+		{name: "Doubles", failures: 6, want: 4 * time.Second},
+		{name: "Capped", failures: 20, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Delay(tt.failures); got != tt.want {
+				t.Errorf("Delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordHasher(t *testing.T) {
+	argon := DefaultPasswordHasher
+	argon.Argon2.Memory = 1024
+	argon.Argon2.Iterations = 1
+
+	bcryptHasher := DefaultPasswordHasher
+	bcryptHasher.Algorithm = AlgorithmBcrypt
+	bcryptHasher.BcryptCost = 4
+
+	argonHash, err := argon.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	bcryptHash, err := bcryptHasher.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		hasher     PasswordHasher
+		password   string
+		hash       string
+		wantErr    bool
+		wantRehash bool
+	}{
+		{
+			name:     "Argon2id matches",
+			hasher:   argon,
+			password: "hunter2",
+			hash:     argonHash,
+		},
+		{
+			name:     "Argon2id mismatch",
+			hasher:   argon,
+			password: "hunter3",
+			hash:     argonHash,
+			wantErr:  true,
+		},
+		{
+			name:       "Bcrypt hash checked and flagged by argon2id hasher",
+			hasher:     argon,
+			password:   "hunter2",
+			hash:       bcryptHash,
+			wantRehash: true,
+		},
+		{
+			name:       "Outdated argon2id parameters",
+			hasher:     DefaultPasswordHasher,
+			password:   "hunter2",
+			hash:       argonHash,
+			wantRehash: true,
+		},
+		{
+			name:       "Bcrypt cost changed",
+			hasher:     PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: 5},
+			password:   "hunter2",
+			hash:       bcryptHash,
+			wantRehash: true,
+		},
+		{
+			name:       "Malformed hash",
+			hasher:     argon,
+			password:   "hunter2",
+			hash:       "$argon2id$v=19$m=1024$bad",
+			wantErr:    true,
+			wantRehash: true,
+		},
+		{
+			name:       "Empty hash of an account without a password",
+			hasher:     argon,
+			password:   "",
+			hash:       "",
+			wantErr:    true,
+			wantRehash: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hasher.Check(tt.password, tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.wantRehash {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "Trimmed and lower-cased",
+			email: "  Bob@Example.COM ",
+			want:  "bob@example.com",
+		},
+		{
+			name:  "Unicode domain",
+			email: "anna@bücher.example",
+			want:  "anna@xn--bcher-kva.example",
+		},
+		{
+			name:  "Trailing dot on domain",
+			email: "bob@example.com.",
+			want:  "bob@example.com",
+		},
+		{
+			name:    "Missing domain",
+			email:   "bob@",
+			wantErr: true,
+		},
+		{
+			name:    "Missing local part",
+			email:   "@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "Space in local part",
+			email:   "bo b@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "Domain without dot",
+			email:   "bob@localhost",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid domain label",
+			email:   "bob@exa_mple.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeEmail(tt.email)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NormalizeEmail() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeEmail() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	breached, err := ReadBreachedPasswords(strings.NewReader(
+		"# sample\n" +
+			"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\n" + // "password"
+			"f7c3bc1d808e04732adf679965ccc34ca7ae3441\n", // "123456789"
+	))
+	if err != nil {
+		t.Fatalf("ReadBreachedPasswords() error = %v", err)
+	}
+	if breached.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", breached.Len())
+	}
+
+	policy := DefaultPasswordPolicy
+	policy.Breached = breached
+
+	tests := []struct {
+		name      string
+		password  string
+		personal  []string
+		wantRules []string
+	}{
+		{
+			name:     "Strong password",
+			password: "correct horse battery staple",
+		},
+		{
+			name:      "Too short",
+			password:  "Xk9#",
+			wantRules: []string{RuleMinLength, RuleMinEntropy},
+		},
+		{
+			name:      "Repetitive",
+			password:  "aaaaaaaaaaaa",
+			wantRules: []string{RuleMinEntropy},
+		},
+		{
+			name:      "Sequential",
+			password:  "abcdefghijklmnop",
+			wantRules: []string{RuleMinEntropy},
+		},
+		{
+			name:      "Breached",
+			password:  "123456789",
+			wantRules: []string{RuleMinEntropy, RuleBreached},
+		},
+		{
+			name:      "Contains email",
+			password:  "my-Rosalind-pass-42",
+			personal:  []string{"rosalind@example.com"},
+			wantRules: []string{RulePersonalInfo},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password, tt.personal...)
+			if len(tt.wantRules) == 0 {
+				if err != nil {
+					t.Fatalf("Check() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var policyErr *PasswordPolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Check() error = %v, want *PasswordPolicyError", err)
+			}
+			got := []string{}
+			for _, v := range policyErr.Violations {
+				got = append(got, v.Rule)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantRules, ",") {
+				t.Errorf("Check() rules = %v, want %v", got, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestReadBreachedPasswordsRejectsBadLines(t *testing.T) {
+	_, err := ReadBreachedPasswords(strings.NewReader("not-a-hash\n"))
+	if err == nil {
+		t.Error("ReadBreachedPasswords() error = nil, want an error")
+	}
+}
+
+func TestMakeDelegatedJWT(t *testing.T) {
+	userID := uuid.New()
+	grantID := uuid.New()
+
+	token, err := MakeDelegatedJWT(userID, "secret", time.Hour, "client-1", grantID, []Scope{ScopeChirpsRead, ScopeProfileWrite})
+	if err != nil {
+		t.Fatalf("MakeDelegatedJWT() error = %v", err)
+	}
+
+	gotID, claims, err := ParseAccessToken(token, "secret")
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+	if gotID != userID {
+		t.Errorf("ParseAccessToken() user = %v, want %v", gotID, userID)
+	}
+	if !claims.IsDelegated() || claims.GrantID != grantID.String() || claims.ClientID != "client-1" {
+		t.Errorf("ParseAccessToken() claims = %+v", claims)
+	}
+	if claims.Scope != "chirps:read profile:write" {
+		t.Errorf("Scope = %q", claims.Scope)
+	}
+
+	sessionToken, _ := MakeJWT(userID, "secret", time.Hour)
+	_, claims, _ = ParseAccessToken(sessionToken, "secret")
+	if claims.IsDelegated() {
+		t.Error("session token reported as delegated")
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalidEmail -
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// NormalizeEmail returns the canonical form used to store and look up an
+// email: surrounding whitespace trimmed, the domain converted to its ASCII
+// (punycode) form and the whole address lower-cased, so that differently
+// typed spellings of one mailbox map to a single account.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", ErrInvalidEmail
+	}
+	local, domain := email[:at], email[at+1:]
+
+	if len(local) > 64 || strings.ContainsRune(local, '@') {
+		return "", ErrInvalidEmail
+	}
+	for _, r := range local {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return "", ErrInvalidEmail
+		}
+	}
+
+	domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(domain, "."))
+	if err != nil || !strings.Contains(domain, ".") {
+		return "", ErrInvalidEmail
+	}
+
+	email = strings.ToLower(local) + "@" + strings.ToLower(domain)
+	if len(email) > 254 {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashAlgorithm names a supported password hashing algorithm.
+type HashAlgorithm string
+
+const (
+	// AlgorithmArgon2id -
+	AlgorithmArgon2id HashAlgorithm = "argon2id"
+	// AlgorithmBcrypt -
+	AlgorithmBcrypt HashAlgorithm = "bcrypt"
+)
+
+// ErrPasswordMismatch -
+var ErrPasswordMismatch = errors.New("password does not match hash")
+
+// ErrUnknownHashFormat -
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Argon2Params are the tunable argon2id parameters.
+type Argon2Params struct {
+	// Memory in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// PasswordHasher hashes new passwords with Algorithm and checks passwords
+// against hashes produced by any supported algorithm.
+//
+// Argon2id hashes are stored in PHC string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//
+// bcrypt hashes keep their native $2a$<cost>$... format.
+type PasswordHasher struct {
+	Algorithm  HashAlgorithm
+	Argon2     Argon2Params
+	BcryptCost int
+}
+
+// DefaultPasswordHasher -
+var DefaultPasswordHasher = PasswordHasher{
+	Algorithm: AlgorithmArgon2id,
+	Argon2: Argon2Params{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	},
+	BcryptCost: bcrypt.DefaultCost,
+}
+
+// Validate reports configuration the hasher can't work with.
+func (h PasswordHasher) Validate() error {
+	switch h.Algorithm {
+	case AlgorithmArgon2id:
+		if h.Argon2.Memory == 0 || h.Argon2.Iterations == 0 || h.Argon2.Parallelism == 0 {
+			return errors.New("argon2id memory, iterations and parallelism must be positive")
+		}
+		if h.Argon2.SaltLength < 8 || h.Argon2.KeyLength < 16 {
+			return errors.New("argon2id salt must be at least 8 bytes and key at least 16 bytes")
+		}
+	case AlgorithmBcrypt:
+		if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return fmt.Errorf("unknown password hash algorithm: %s", h.Algorithm)
+	}
+	return nil
+}
+
+// Hash -
+func (h PasswordHasher) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case AlgorithmArgon2id:
+		return h.hashArgon2id(password)
+	case AlgorithmBcrypt:
+		dat, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(dat), nil
+	}
+	return "", fmt.Errorf("unknown password hash algorithm: %s", h.Algorithm)
+}
+
+// Check -
+func (h PasswordHasher) Check(password, hash string) error {
+	switch hashAlgorithm(hash) {
+	case AlgorithmArgon2id:
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return err
+		}
+		got := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(got, key) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	case AlgorithmBcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	}
+	return ErrUnknownHashFormat
+}
+
+// NeedsRehash reports whether hash was made with a different algorithm or
+// weaker parameters than the hasher is configured with.
+func (h PasswordHasher) NeedsRehash(hash string) bool {
+	if hashAlgorithm(hash) != h.Algorithm {
+		return true
+	}
+
+	switch h.Algorithm {
+	case AlgorithmArgon2id:
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return true
+		}
+		return params.Memory != h.Argon2.Memory ||
+			params.Iterations != h.Argon2.Iterations ||
+			params.Parallelism != h.Argon2.Parallelism ||
+			uint32(len(salt)) != h.Argon2.SaltLength ||
+			uint32(len(key)) != h.Argon2.KeyLength
+	case AlgorithmBcrypt:
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return true
+		}
+		return cost != h.BcryptCost
+	}
+	return true
+}
+
+func (h PasswordHasher) hashArgon2id(password string) (string, error) {
+	salt := make([]byte, h.Argon2.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Argon2.Iterations, h.Argon2.Memory, h.Argon2.Parallelism, h.Argon2.KeyLength)
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.Argon2.Memory,
+		h.Argon2.Iterations,
+		h.Argon2.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func hashAlgorithm(hash string) HashAlgorithm {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return AlgorithmArgon2id
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return AlgorithmBcrypt
+	}
+	return ""
+}
+
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHashFormat
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2 version: %d", version)
+	}
+
+	params := Argon2Params{}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrUnknownHashFormat
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
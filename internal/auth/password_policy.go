@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password policy rule names reported in PasswordRuleViolation.
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleMinEntropy   = "min_entropy"
+	RulePersonalInfo = "personal_info"
+	RuleBreached     = "breached"
+)
+
+// PasswordRuleViolation explains one rule a password failed.
+type PasswordRuleViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password failed, so the user can
+// fix them all at once.
+type PasswordPolicyError struct {
+	Violations []PasswordRuleViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	rules := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		rules[i] = v.Rule
+	}
+	return "password violates policy: " + strings.Join(rules, ", ")
+}
+
+// PasswordPolicy decides which new passwords are acceptable. Lengths are
+// counted in characters, not bytes.
+type PasswordPolicy struct {
+	MinLength      int
+	MaxLength      int
+	MinEntropyBits float64
+	// Breached is optional; when set, passwords found in it are rejected.
+	Breached *BreachedPasswords
+}
+
+// DefaultPasswordPolicy -
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:      8,
+	MaxLength:      128,
+	MinEntropyBits: 40,
+}
+
+// Check returns a *PasswordPolicyError if password breaks any rule.
+// personalInfo holds values such as the user's email or handle that the
+// password must not contain.
+func (p PasswordPolicy) Check(password string, personalInfo ...string) error {
+	violations := []PasswordRuleViolation{}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, PasswordRuleViolation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, PasswordRuleViolation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %d characters", p.MaxLength),
+		})
+	}
+
+	if EstimateEntropy(password) < p.MinEntropyBits {
+		violations = append(violations, PasswordRuleViolation{
+			Rule:    RuleMinEntropy,
+			Message: "Password is too predictable; use a longer password or mix in other kinds of characters",
+		})
+	}
+
+	lower := strings.ToLower(password)
+	for _, info := range personalInfo {
+		// emails are only matched on the part before the @
+		info, _, _ = strings.Cut(strings.ToLower(info), "@")
+		if len(info) >= 3 && strings.Contains(lower, info) {
+			violations = append(violations, PasswordRuleViolation{
+				Rule:    RulePersonalInfo,
+				Message: "Password must not contain your email or handle",
+			})
+			break
+		}
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, PasswordRuleViolation{
+			Rule:    RuleBreached,
+			Message: "Password has appeared in a data breach; choose a different one",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// EstimateEntropy gives a rough strength in bits: the size of the
+// character classes used, raised to the number of characters that don't
+// just repeat or continue a run from the previous one ("aaaa", "1234").
+func EstimateEntropy(password string) float64 {
+	var hasLower, hasUpper, hasDigit, hasSymbol, hasOther bool
+	effective := 0
+	var prev rune = -1
+	for _, r := range password {
+		switch {
+		case r < utf8.RuneSelf && unicode.IsLower(r):
+			hasLower = true
+		case r < utf8.RuneSelf && unicode.IsUpper(r):
+			hasUpper = true
+		case r < utf8.RuneSelf && unicode.IsDigit(r):
+			hasDigit = true
+		case r < utf8.RuneSelf:
+			hasSymbol = true
+		default:
+			hasOther = true
+		}
+		if prev < 0 || (r != prev && r != prev+1 && r != prev-1) {
+			effective++
+		}
+		prev = r
+	}
+
+	pool := 0
+	if hasLower {
+		pool += 26
+	}
+	if hasUpper {
+		pool += 26
+	}
+	if hasDigit {
+		pool += 10
+	}
+	if hasSymbol {
+		pool += 33
+	}
+	if hasOther {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(effective) * math.Log2(float64(pool))
+}
+
+// BreachedPasswords is a set of SHA-1 password hashes from known breaches,
+// bucketed by the first five hex characters of the hash the same way the
+// Pwned Passwords range API is, so the list can be built from its range
+// files.
+type BreachedPasswords struct {
+	buckets map[string]map[string]struct{}
+	size    int
+}
+
+// LoadBreachedPasswords reads a breached password list from a file; see
+// ReadBreachedPasswords for the format.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBreachedPasswords(f)
+}
+
+// ReadBreachedPasswords parses one full upper- or lower-case SHA-1 hex hash
+// per line, optionally followed by ":count" as in the Pwned Passwords
+// downloads. Blank lines and lines starting with # are ignored.
+func ReadBreachedPasswords(r io.Reader) (*BreachedPasswords, error) {
+	b := &BreachedPasswords{buckets: map[string]map[string]struct{}{}}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		_, err := hex.DecodeString(hash)
+		if err != nil || len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("line %d: not a SHA-1 hex hash", lineNo)
+		}
+		b.add(hash)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *BreachedPasswords) add(hash string) {
+	prefix, suffix := hash[:5], hash[5:]
+	bucket, ok := b.buckets[prefix]
+	if !ok {
+		bucket = map[string]struct{}{}
+		b.buckets[prefix] = bucket
+	}
+	if _, ok := bucket[suffix]; !ok {
+		bucket[suffix] = struct{}{}
+		b.size++
+	}
+}
+
+// Len returns how many distinct hashes are loaded.
+func (b *BreachedPasswords) Len() int {
+	return b.size
+}
+
+// Contains -
+func (b *BreachedPasswords) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	_, ok := b.buckets[hash[:5]][hash[5:]]
+	return ok
+}
@@ -0,0 +1,22 @@
+package auth
+
+// Role is the authorization level stored on a user.
+type Role string
+
+const (
+	// RoleUser -
+	RoleUser Role = "user"
+	// RoleModerator -
+	RoleModerator Role = "moderator"
+	// RoleAdmin -
+	RoleAdmin Role = "admin"
+)
+
+// ParseRole reports whether name is a known role.
+func ParseRole(name string) (Role, bool) {
+	switch Role(name) {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return Role(name), true
+	}
+	return "", false
+}
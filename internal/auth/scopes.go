@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Scope names a permission that can be granted to a personal access token.
+type Scope string
+
+const (
+	// ScopeChirpsRead -
+	ScopeChirpsRead Scope = "chirps:read"
+	// ScopeChirpsWrite -
+	ScopeChirpsWrite Scope = "chirps:write"
+	// ScopeProfileWrite -
+	ScopeProfileWrite Scope = "profile:write"
+)
+
+// AllScopes lists every scope a token can be issued with.
+var AllScopes = []Scope{
+	ScopeChirpsRead,
+	ScopeChirpsWrite,
+	ScopeProfileWrite,
+}
+
+// PersonalAccessTokenPrefix marks bearer tokens that are personal access
+// tokens rather than JWTs.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+// ParseScopes validates a list of scope names, dropping duplicates.
+func ParseScopes(names []string) ([]Scope, error) {
+	seen := make(map[Scope]bool, len(names))
+	scopes := make([]Scope, 0, len(names))
+	for _, name := range names {
+		scope := Scope(name)
+		if !isKnownScope(scope) {
+			return nil, fmt.Errorf("unknown scope: %s", name)
+		}
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+func isKnownScope(scope Scope) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// MakePersonalAccessToken makes a random 256 bit token
+// encoded in hex and prefixed with PersonalAccessTokenPrefix
+func MakePersonalAccessToken() (string, error) {
+	token := make([]byte, 32)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + hex.EncodeToString(token), nil
+}
+
+// IsPersonalAccessToken -
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// HashToken returns the hex encoded SHA-256 digest of an opaque token,
+// which is what gets stored in the database.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
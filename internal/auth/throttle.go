@@ -0,0 +1,41 @@
+package auth
+
+import "time"
+
+// BackoffPolicy describes how long a login key (an account or an IP) is
+// locked out after a run of consecutive failed attempts.
+type BackoffPolicy struct {
+	// FreeAttempts failures are allowed before any lockout applies.
+	FreeAttempts int
+	// BaseDelay is the lockout after the first failure past FreeAttempts,
+	// doubling with every failure after that.
+	BaseDelay time.Duration
+	// MaxDelay caps the lockout.
+	MaxDelay time.Duration
+}
+
+// Delay returns how long to lock out after failures consecutive failures.
+func (p BackoffPolicy) Delay(failures int) time.Duration {
+	over := failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < over; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// LockedUntil returns the time a key stays locked out until, given its
+// failure count and the time of its latest failure.
+func (p BackoffPolicy) LockedUntil(failures int, lastFailure time.Time) time.Time {
+	return lastFailure.Add(p.Delay(failures))
+}
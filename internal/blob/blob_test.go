@@ -0,0 +1,86 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store := LocalStore{Dir: t.TempDir()}
+
+	err := store.Put(ctx, "exports/a.zip", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	rc, err := store.Open(ctx, "exports/a.zip")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	dat, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(dat) != "hello" {
+		t.Fatalf("Open() read %q, %v; want %q", dat, err, "hello")
+	}
+
+	err = store.Delete(ctx, "exports/a.zip")
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	err = store.Delete(ctx, "exports/a.zip")
+	if err != nil {
+		t.Fatalf("Delete() of missing blob error = %v", err)
+	}
+
+	for _, key := range []string{"", "/etc/passwd", "../escape", "exports/../../escape", "exports//a"} {
+		err := store.Put(ctx, key, strings.NewReader("x"))
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestURLSigner(t *testing.T) {
+	signer := URLSigner{Secret: []byte("secret")}
+	now := time.Now()
+	path := "/api/exports/1/download"
+
+	query, err := url.ParseQuery(signer.Sign(path, now.Add(time.Minute)))
+	if err != nil {
+		t.Fatalf("ParseQuery() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		signer  URLSigner
+		path    string
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", signer, path, now, nil},
+		{"expired", signer, path, now.Add(2 * time.Minute), ErrExpiredSignature},
+		{"other path", signer, "/api/exports/2/download", now, ErrInvalidSignature},
+		{"other secret", URLSigner{Secret: []byte("other")}, path, now, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.signer.Verify(tt.path, query, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	tampered := url.Values{"expires": {query.Get("expires") + "0"}, "signature": {query.Get("signature")}}
+	err = signer.Verify(path, tampered, now)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() with tampered expiry error = %v, want ErrInvalidSignature", err)
+	}
+}
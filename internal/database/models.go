@@ -6,6 +6,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +18,188 @@ type Chirp struct {
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	HiddenAt  sql.NullTime
+}
+
+type ChirpReport struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ChirpID    uuid.NullUUID
+	ChirpBody  string
+	AuthorID   uuid.UUID
+	ReporterID uuid.UUID
+	Reason     string
+	Details    string
+	AssigneeID uuid.NullUUID
+	Resolution sql.NullString
+	ResolvedBy uuid.NullUUID
+	ResolvedAt sql.NullTime
+}
+
+type DataExport struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Status      string
+	BlobKey     sql.NullString
+	Error       sql.NullString
+	CompletedAt sql.NullTime
+	ExpiresAt   sql.NullTime
+}
+
+type EmailVerificationToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type ExternalIdentity struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Provider    string
+	Subject     string
+	Email       sql.NullString
+	LastLoginAt sql.NullTime
+}
+
+type InviteCode struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Code      string
+	CreatedBy uuid.UUID
+	MaxUses   int32
+	Uses      int32
+	ExpiresAt sql.NullTime
+	RevokedAt sql.NullTime
+}
+
+type LoginAttempt struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Email     string
+	IpAddress string
+	UserID    uuid.NullUUID
+	Succeeded bool
+}
+
+type MagicLinkToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type MailOutbox struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	Recipient     string
+	Subject       string
+	Body          string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+	SentAt        sql.NullTime
+}
+
+type ModerationAction struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	ModeratorID  uuid.NullUUID
+	Action       string
+	ReportID     uuid.NullUUID
+	ChirpID      uuid.NullUUID
+	TargetUserID uuid.NullUUID
+	Note         string
+}
+
+type MuteRule struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.UUID
+	Keyword     sql.NullString
+	WholeWord   bool
+	Regex       bool
+	MutedUserID uuid.NullUUID
+	ExpiresAt   sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	CreatedAt     time.Time
+	GrantID       uuid.UUID
+	RedirectUri   string
+	CodeChallenge string
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	Scopes       []string
+}
+
+type OauthGrant struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ClientID   uuid.UUID
+	Scopes     []string
+	LastUsedAt sql.NullTime
+}
+
+type OauthRefreshToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	GrantID   uuid.UUID
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+type OidcLoginState struct {
+	StateHash    string
+	CreatedAt    time.Time
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	LinkUserID   uuid.NullUUID
+	ExpiresAt    time.Time
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
 }
 
 type RefreshToken struct {
@@ -28,11 +211,96 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type SpamHold struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	Score     float64
+	Rules     []string
+}
+
+type Subscription struct {
+	UserID           uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Status           string
+	CurrentPeriodEnd time.Time
+	CanceledAt       sql.NullTime
+}
+
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	Role            string
+	EmailVerifiedAt sql.NullTime
+	PendingEmail    sql.NullString
+	Handle          sql.NullString
+	DisplayName     sql.NullString
+	Bio             sql.NullString
+	AvatarUrl       sql.NullString
+	DeletedAt       sql.NullTime
+	InvitedBy       uuid.NullUUID
+	InviteCodeID    uuid.NullUUID
+}
+
+type UserRestriction struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Kind      string
+	Reason    string
+	ExpiresAt sql.NullTime
+	CreatedBy uuid.NullUUID
+	LiftedAt  sql.NullTime
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	EndpointID    uuid.UUID
+	EventID       uuid.UUID
+	EventType     string
+	Payload       json.RawMessage
+	Attempts      int32
+	NextAttemptAt time.Time
+	DeliveredAt   sql.NullTime
+	LastError     sql.NullString
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          uuid.UUID
+	DeliveryID  uuid.UUID
+	AttemptedAt time.Time
+	StatusCode  sql.NullInt32
+	Error       sql.NullString
+	DurationMs  int32
+}
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	OwnerID             uuid.NullUUID
+	Url                 string
+	Secret              string
+	EventTypes          []string
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID
+	Provider    string
+	EventID     string
+	EventType   string
+	Payload     json.RawMessage
+	ReceivedAt  time.Time
+	Outcome     string
+	Error       sql.NullString
+	Attempts    int32
+	ProcessedAt sql.NullTime
+	ClaimedAt   sql.NullTime
 }
@@ -0,0 +1,214 @@
+// Package entitlements maps subscription plans to the features and limits
+// they come with, so handlers ask "may this user pin chirps?" instead of
+// checking which plan the user is on.
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"sort"
+)
+
+// Plan -
+type Plan string
+
+const (
+	// PlanFree -
+	PlanFree Plan = "free"
+	// PlanChirpyRed -
+	PlanChirpyRed Plan = "chirpy_red"
+)
+
+// Capability names a feature that is either on or off for a plan.
+type Capability string
+
+const (
+	// CapabilityEditChirps -
+	CapabilityEditChirps Capability = "edit_chirps"
+	// CapabilityPinChirps -
+	CapabilityPinChirps Capability = "pin_chirps"
+	// CapabilityUploadMedia -
+	CapabilityUploadMedia Capability = "upload_media"
+)
+
+// Limit names a numeric allowance for a plan.
+type Limit string
+
+const (
+	// LimitChirpLength is the longest chirp body, in bytes.
+	LimitChirpLength Limit = "chirp_length"
+	// LimitPinnedChirps is how many chirps can be pinned at once.
+	LimitPinnedChirps Limit = "pinned_chirps"
+	// LimitEditWindowSeconds is how long after posting a chirp can be edited.
+	LimitEditWindowSeconds Limit = "edit_window_seconds"
+	// LimitUploadBytes is the largest media upload.
+	LimitUploadBytes Limit = "upload_bytes"
+)
+
+// AllCapabilities -
+var AllCapabilities = []Capability{CapabilityEditChirps, CapabilityPinChirps, CapabilityUploadMedia}
+
+// AllLimits -
+var AllLimits = []Limit{LimitChirpLength, LimitPinnedChirps, LimitEditWindowSeconds, LimitUploadBytes}
+
+// Entitlements is what one plan allows.
+type Entitlements struct {
+	Plan         Plan
+	Capabilities map[Capability]bool
+	Limits       map[Limit]int64
+}
+
+// Has -
+func (e Entitlements) Has(capability Capability) bool {
+	return e.Capabilities[capability]
+}
+
+// Limit returns the plan's allowance, which is 0 for limits it doesn't set.
+func (e Entitlements) Limit(limit Limit) int64 {
+	return e.Limits[limit]
+}
+
+// Catalog holds the entitlements of every plan.
+type Catalog map[Plan]Entitlements
+
+// DefaultCatalog is used when no entitlements file is configured.
+var DefaultCatalog = Catalog{
+	PlanFree: {
+		Plan:         PlanFree,
+		Capabilities: map[Capability]bool{},
+		Limits: map[Limit]int64{
+			LimitChirpLength:       140,
+			LimitPinnedChirps:      0,
+			LimitEditWindowSeconds: 0,
+			LimitUploadBytes:       0,
+		},
+	},
+	PlanChirpyRed: {
+		Plan: PlanChirpyRed,
+		Capabilities: map[Capability]bool{
+			CapabilityEditChirps:  true,
+			CapabilityPinChirps:   true,
+			CapabilityUploadMedia: true,
+		},
+		Limits: map[Limit]int64{
+			LimitChirpLength:       280,
+			LimitPinnedChirps:      3,
+			LimitEditWindowSeconds: 15 * 60,
+			LimitUploadBytes:       5 << 20,
+		},
+	},
+}
+
+// For returns the entitlements of plan. Plans missing from the catalog
+// get nothing.
+func (c Catalog) For(plan Plan) Entitlements {
+	e, ok := c[plan]
+	if !ok {
+		return Entitlements{Plan: plan}
+	}
+	return e
+}
+
+// fileFormat is the JSON layout of an entitlements file:
+//
+//	{"plans": {"free": {"capabilities": [], "limits": {"chirp_length": 140}}}}
+type fileFormat struct {
+	Plans map[Plan]struct {
+		Capabilities []Capability    `json:"capabilities"`
+		Limits       map[Limit]int64 `json:"limits"`
+	} `json:"plans"`
+}
+
+// Load reads a catalog from the JSON file at path.
+func Load(path string) (Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read parses a catalog in the entitlements file format. Every known plan
+// has to be listed, and unknown capability or limit names are rejected so
+// typos don't silently switch features off. Limits a plan leaves out keep
+// their value from DefaultCatalog, so adding a limit doesn't cut existing
+// files down to 0.
+func Read(r io.Reader) (Catalog, error) {
+	var file fileFormat
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entitlements file: %w", err)
+	}
+
+	catalog := Catalog{}
+	for plan, spec := range file.Plans {
+		if plan != PlanFree && plan != PlanChirpyRed {
+			return nil, fmt.Errorf("unknown plan %q", plan)
+		}
+
+		e := Entitlements{
+			Plan:         plan,
+			Capabilities: map[Capability]bool{},
+			Limits:       maps.Clone(DefaultCatalog[plan].Limits),
+		}
+		for _, capability := range spec.Capabilities {
+			if !isKnownCapability(capability) {
+				return nil, fmt.Errorf("plan %s: unknown capability %q", plan, capability)
+			}
+			e.Capabilities[capability] = true
+		}
+		for limit, value := range spec.Limits {
+			if !isKnownLimit(limit) {
+				return nil, fmt.Errorf("plan %s: unknown limit %q", plan, limit)
+			}
+			if value < 0 {
+				return nil, fmt.Errorf("plan %s: limit %s can't be negative", plan, limit)
+			}
+			e.Limits[limit] = value
+		}
+		catalog[plan] = e
+	}
+
+	for _, plan := range []Plan{PlanFree, PlanChirpyRed} {
+		if _, ok := catalog[plan]; !ok {
+			return nil, fmt.Errorf("plan %s is missing", plan)
+		}
+	}
+	return catalog, nil
+}
+
+// CapabilityNames returns the capabilities e has, sorted.
+func (e Entitlements) CapabilityNames() []string {
+	names := []string{}
+	for capability, ok := range e.Capabilities {
+		if ok {
+			names = append(names, string(capability))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isKnownCapability(capability Capability) bool {
+	for _, c := range AllCapabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+func isKnownLimit(limit Limit) bool {
+	for _, l := range AllLimits {
+		if l == limit {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package entitlements
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	catalog, err := Read(strings.NewReader(`{
+		"plans": {
+			"free": {"capabilities": [], "limits": {"chirp_length": 100}},
+			"chirpy_red": {"capabilities": ["pin_chirps"], "limits": {"chirp_length": 500, "pinned_chirps": 5}}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	free := catalog.For(PlanFree)
+	if free.Has(CapabilityPinChirps) || free.Limit(LimitChirpLength) != 100 {
+		t.Errorf("free = %+v", free)
+	}
+
+	red := catalog.For(PlanChirpyRed)
+	if !red.Has(CapabilityPinChirps) || red.Has(CapabilityEditChirps) {
+		t.Errorf("chirpy_red capabilities = %v", red.Capabilities)
+	}
+	// limits the file leaves out come from DefaultCatalog
+	if red.Limit(LimitPinnedChirps) != 5 || red.Limit(LimitUploadBytes) != 5<<20 {
+		t.Errorf("chirpy_red limits = %v", red.Limits)
+	}
+	if got := red.CapabilityNames(); !reflect.DeepEqual(got, []string{"pin_chirps"}) {
+		t.Errorf("CapabilityNames() = %v", got)
+	}
+}
+
+func TestReadEmptyLimits(t *testing.T) {
+	catalog, err := Read(strings.NewReader(`{"plans": {"free": {"limits": {}}, "chirpy_red": {}}}`))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	for _, plan := range []Plan{PlanFree, PlanChirpyRed} {
+		if !reflect.DeepEqual(catalog.For(plan).Limits, DefaultCatalog.For(plan).Limits) {
+			t.Errorf("%s limits = %v, want the defaults", plan, catalog.For(plan).Limits)
+		}
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"Missing plan", `{"plans": {"free": {}}}`},
+		{"Unknown plan", `{"plans": {"free": {}, "chirpy_red": {}, "gold": {}}}`},
+		{"Unknown capability", `{"plans": {"free": {"capabilities": ["time_travel"]}, "chirpy_red": {}}}`},
+		{"Unknown limit", `{"plans": {"free": {"limits": {"chirp_lenght": 1}}, "chirpy_red": {}}}`},
+		{"Negative limit", `{"plans": {"free": {"limits": {"chirp_length": -1}}, "chirpy_red": {}}}`},
+		{"Unknown field", `{"plans": {"free": {}, "chirpy_red": {}}, "extra": true}`},
+		{"Not JSON", `plans`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.file))
+			if err == nil {
+				t.Error("Read() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestDefaultCatalog(t *testing.T) {
+	for _, plan := range []Plan{PlanFree, PlanChirpyRed} {
+		e := DefaultCatalog.For(plan)
+		for _, limit := range AllLimits {
+			if _, ok := e.Limits[limit]; !ok {
+				t.Errorf("default %s plan doesn't set %s", plan, limit)
+			}
+		}
+	}
+	if DefaultCatalog.For(PlanFree).Limit(LimitChirpLength) != 140 {
+		t.Error("free chirps should stay at 140 bytes")
+	}
+	if len(DefaultCatalog.For("unknown").Capabilities) != 0 {
+		t.Error("unknown plans should get nothing")
+	}
+}
@@ -0,0 +1,222 @@
+package mail
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/database"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer accepts messages for delivery. Handlers depend on this rather than
+// on a concrete transport.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Sender hands a message to the outside world.
+type Sender interface {
+	Deliver(ctx context.Context, msg Message) error
+}
+
+// Outbox is a Mailer that queues messages in the mail_outbox table; a
+// Dispatcher delivers them later, so a slow or failing mail server never
+// holds up a request.
+type Outbox struct {
+	db *database.Queries
+}
+
+// NewOutbox -
+func NewOutbox(db *database.Queries) *Outbox {
+	return &Outbox{db: db}
+}
+
+// Send -
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	_, err := o.db.CreateOutboxMessage(ctx, database.CreateOutboxMessageParams{
+		ID:        uuid.New(),
+		Recipient: msg.To,
+		Subject:   msg.Subject,
+		Body:      msg.Body,
+	})
+	return err
+}
+
+// Recorder keeps every message it is given in memory instead of sending
+// it. It is both a Mailer and a Sender, so tests can use it in place of the
+// outbox or of a real transport.
+type Recorder struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Send -
+func (r *Recorder) Send(ctx context.Context, msg Message) error {
+	return r.Deliver(ctx, msg)
+}
+
+// Deliver -
+func (r *Recorder) Deliver(ctx context.Context, msg Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of everything recorded so far.
+func (r *Recorder) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.messages...)
+}
+
+// FileSender writes each message to its own .eml file in Dir, which is
+// enough for local development and tests.
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+// Deliver -
+func (s FileSender) Deliver(ctx context.Context, msg Message) error {
+	err := os.MkdirAll(s.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), uuid.NewString())
+	return os.WriteFile(filepath.Join(s.Dir, name), formatMessage(s.From, msg), 0o644)
+}
+
+// SMTPSender delivers through an SMTP relay. Username is optional; when it
+// is set PLAIN auth is used.
+type SMTPSender struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// Deliver -
+func (s SMTPSender) Deliver(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host := s.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, formatMessage(s.From, msg))
+}
+
+func formatMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// Dispatcher drains the outbox into a Sender, retrying failed messages with
+// exponential backoff up to MaxAttempts times.
+type Dispatcher struct {
+	DB          *database.Queries
+	Sender      Sender
+	Interval    time.Duration
+	BatchSize   int32
+	MaxAttempts int32
+}
+
+// Run dispatches pending messages every Interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := d.DispatchPending(ctx)
+		if err != nil {
+			log.Printf("Error dispatching mail: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchPending delivers one batch of due messages and returns how many
+// were sent.
+func (d *Dispatcher) DispatchPending(ctx context.Context) (int, error) {
+	pending, err := d.DB.ListPendingOutboxMessages(ctx, database.ListPendingOutboxMessagesParams{
+		MaxAttempts: d.MaxAttempts,
+		BatchSize:   d.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, msg := range pending {
+		err := d.Sender.Deliver(ctx, Message{
+			To:      msg.Recipient,
+			Subject: msg.Subject,
+			Body:    msg.Body,
+		})
+		if err != nil {
+			log.Printf("Error delivering mail %s: %v", msg.ID, err)
+			err = d.DB.MarkOutboxMessageFailed(ctx, database.MarkOutboxMessageFailedParams{
+				ID:            msg.ID,
+				LastError:     sql.NullString{String: err.Error(), Valid: true},
+				NextAttemptAt: time.Now().UTC().Add(RetryDelay(int(msg.Attempts) + 1)),
+			})
+			if err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		err = d.DB.MarkOutboxMessageSent(ctx, msg.ID)
+		if err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// RetryDelay returns how long to wait before the next delivery attempt
+// after attempts failures: one minute, doubling up to an hour.
+func RetryDelay(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= time.Hour {
+			return time.Hour
+		}
+	}
+	return delay
+}
@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSenderDeliver(t *testing.T) {
+	dir := t.TempDir()
+	sender := FileSender{Dir: dir, From: "chirpy@localhost"}
+
+	err := sender.Deliver(context.Background(), Message{
+		To:      "bob@example.com",
+		Subject: "Hello",
+		Body:    "line one\nline two",
+	})
+	if err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v (err %v)", files, err)
+	}
+
+	dat, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	for _, want := range []string{"From: chirpy@localhost\r\n", "To: bob@example.com\r\n", "Subject: Hello\r\n", "line one\r\nline two"} {
+		if !strings.Contains(string(dat), want) {
+			t.Errorf("message missing %q:\n%s", want, dat)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Minute},
+		{attempts: 3, want: 4 * time.Minute},
+		{attempts: 10, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := RetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("RetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var _ Mailer = &Recorder{}
+	var _ Sender = &Recorder{}
+
+	rec := &Recorder{}
+	rec.Send(context.Background(), Message{To: "a@example.com", Subject: "One"})
+	rec.Deliver(context.Background(), Message{To: "b@example.com", Subject: "Two"})
+
+	got := rec.Messages()
+	if len(got) != 2 || got[0].Subject != "One" || got[1].Subject != "Two" {
+		t.Fatalf("Messages() = %+v", got)
+	}
+
+	got[0].Subject = "changed"
+	if rec.Messages()[0].Subject != "One" {
+		t.Error("Messages() should return a copy")
+	}
+}
@@ -0,0 +1,129 @@
+// Package mute decides whether a chirp matches any of a user's mute rules.
+//
+// A rule mutes either an author or a keyword. Keywords match case
+// insensitively anywhere in a chirp, as a whole word, or as a regular
+// expression. Expressions use Go's RE2 syntax, which runs in linear time,
+// so a user's pattern can't stall a feed.
+package mute
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MaxKeywordLength is the longest keyword or expression a rule can hold.
+const MaxKeywordLength = 200
+
+// ErrEmptyKeyword -
+var ErrEmptyKeyword = errors.New("keyword can't be empty")
+
+// ErrKeywordTooLong -
+var ErrKeywordTooLong = errors.New("keyword is too long")
+
+// Rule is one thing a user doesn't want to see. AuthorID is set for author
+// mutes and Keyword for the rest.
+type Rule struct {
+	Keyword   string
+	WholeWord bool
+	Regex     bool
+	AuthorID  uuid.UUID
+}
+
+// Filter is a compiled set of rules.
+type Filter struct {
+	authors    map[uuid.UUID]bool
+	substrings []string
+	patterns   []*regexp.Regexp
+}
+
+// InvalidRule is a rule Compile left out, identified by its index in the
+// rules it was given.
+type InvalidRule struct {
+	Index int
+	Err   error
+}
+
+// Compile prepares rules for matching. Keyword rules Validate would
+// reject, such as ones saved before a limit was tightened, are left out
+// and returned so the caller can report them.
+func Compile(rules []Rule) (*Filter, []InvalidRule) {
+	f := &Filter{authors: map[uuid.UUID]bool{}}
+	var invalid []InvalidRule
+	for i, rule := range rules {
+		if rule.AuthorID != uuid.Nil {
+			f.authors[rule.AuthorID] = true
+			continue
+		}
+		if !rule.Regex && !rule.WholeWord {
+			err := checkKeyword(rule.Keyword)
+			if err != nil {
+				invalid = append(invalid, InvalidRule{Index: i, Err: err})
+				continue
+			}
+			f.substrings = append(f.substrings, strings.ToLower(rule.Keyword))
+			continue
+		}
+		pattern, err := compileKeyword(rule.Keyword, rule.WholeWord, rule.Regex)
+		if err != nil {
+			invalid = append(invalid, InvalidRule{Index: i, Err: err})
+			continue
+		}
+		f.patterns = append(f.patterns, pattern)
+	}
+	return f, invalid
+}
+
+// Validate checks a keyword rule before it is saved.
+func Validate(keyword string, wholeWord, regex bool) error {
+	_, err := compileKeyword(keyword, wholeWord, regex)
+	return err
+}
+
+func checkKeyword(keyword string) error {
+	if strings.TrimSpace(keyword) == "" {
+		return ErrEmptyKeyword
+	}
+	if len(keyword) > MaxKeywordLength {
+		return ErrKeywordTooLong
+	}
+	return nil
+}
+
+func compileKeyword(keyword string, wholeWord, regex bool) (*regexp.Regexp, error) {
+	err := checkKeyword(keyword)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := keyword
+	if !regex {
+		pattern = regexp.QuoteMeta(keyword)
+	}
+	if wholeWord {
+		// \b only knows ASCII word characters, so spell the boundary out
+		pattern = `(?:^|[^\pL\pN_])(?:` + pattern + `)(?:$|[^\pL\pN_])`
+	}
+	return regexp.Compile(`(?i)` + pattern)
+}
+
+// Muted reports whether a chirp by authorID with body matches any rule.
+func (f *Filter) Muted(authorID uuid.UUID, body string) bool {
+	if f.authors[authorID] {
+		return true
+	}
+	lower := strings.ToLower(body)
+	for _, s := range f.substrings {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	for _, pattern := range f.patterns {
+		if pattern.MatchString(body) {
+			return true
+		}
+	}
+	return false
+}
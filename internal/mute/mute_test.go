@@ -0,0 +1,90 @@
+package mute
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFilterMuted(t *testing.T) {
+	mutedAuthor := uuid.New()
+	otherAuthor := uuid.New()
+
+	filter, invalid := Compile([]Rule{
+		{AuthorID: mutedAuthor},
+		{Keyword: "Spoiler"},
+		{Keyword: "cat", WholeWord: true},
+		{Keyword: "café", WholeWord: true},
+		{Keyword: `crypto\s*coin`, Regex: true},
+	})
+	if len(invalid) != 0 {
+		t.Fatalf("Compile() invalid = %v", invalid)
+	}
+
+	tests := []struct {
+		name   string
+		author uuid.UUID
+		body   string
+		want   bool
+	}{
+		{"Muted author", mutedAuthor, "anything at all", true},
+		{"Clean chirp", otherAuthor, "a lovely day", false},
+		{"Keyword anywhere", otherAuthor, "major SPOILERS ahead", true},
+		{"Whole word", otherAuthor, "my cat is asleep", true},
+		{"Whole word with punctuation", otherAuthor, "Cat!", true},
+		{"Whole word inside another word", otherAuthor, "concatenate", false},
+		{"Whole word with non-ASCII letters", otherAuthor, "cafés are open", false},
+		{"Whole non-ASCII word", otherAuthor, "meet at the Café", true},
+		{"Regex", otherAuthor, "buy CryptoCoin now", true},
+		{"Regex no match", otherAuthor, "crypto is a word", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.Muted(tt.author, tt.body); got != tt.want {
+				t.Errorf("Muted(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileSkipsInvalidRules(t *testing.T) {
+	filter, invalid := Compile([]Rule{
+		{Keyword: "a(b", Regex: true},
+		{Keyword: "spoiler"},
+		{Keyword: string(make([]byte, MaxKeywordLength+1))},
+		{Keyword: "cat", WholeWord: true},
+	})
+
+	if len(invalid) != 2 || invalid[0].Index != 0 || invalid[1].Index != 2 {
+		t.Fatalf("Compile() invalid = %v, want rules 0 and 2", invalid)
+	}
+	if !filter.Muted(uuid.New(), "no spoilers please") || !filter.Muted(uuid.New(), "my cat") {
+		t.Error("valid rules were dropped along with the invalid ones")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyword string
+		regex   bool
+		wantErr bool
+	}{
+		{"Plain keyword", "hello", false, false},
+		{"Regex metacharacters are literal without regex", "a(b", false, false},
+		{"Valid regex", "a(b|c)", true, false},
+		{"Invalid regex", "a(b", true, true},
+		{"Empty", "  ", false, true},
+		{"Too long", string(make([]byte, MaxKeywordLength+1)), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.keyword, false, tt.regex)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/iamjoona/chippy/internal/oidc"
+	"github.com/iamjoona/chippy/internal/oidc/oidctest"
+)
+
+func newProvider(t *testing.T) (*oidctest.Issuer, *oidc.Provider) {
+	t.Helper()
+	issuer, err := oidctest.NewIssuer("chirpy", "s3cret")
+	if err != nil {
+		t.Fatalf("NewIssuer() error = %v", err)
+	}
+	t.Cleanup(issuer.Close)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       issuer.URL,
+		ClientID:     "chirpy",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://localhost:8080/api/auth/oidc/test/callback",
+	})
+	return issuer, provider
+}
+
+// signIn runs the browser part of the flow and returns the code.
+func signIn(t *testing.T, issuer *oidctest.Issuer, provider *oidc.Provider, verifier, nonce string) string {
+	t.Helper()
+	authURL, err := provider.AuthCodeURL(context.Background(), "state-1", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+
+	redirect, err := issuer.Authorize(authURL, oidctest.Identity{
+		Subject:       "user-42",
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		Name:          "Ada",
+	})
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if redirect.Query().Get("state") != "state-1" {
+		t.Fatalf("state = %q, want state-1", redirect.Query().Get("state"))
+	}
+	return redirect.Query().Get("code")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	issuer, provider := newProvider(t)
+	ctx := context.Background()
+
+	verifier, err := oidc.NewPKCEVerifier()
+	if err != nil {
+		t.Fatalf("NewPKCEVerifier() error = %v", err)
+	}
+
+	code := signIn(t, issuer, provider, verifier, "nonce-1")
+	claims, err := provider.Exchange(ctx, code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if claims.Subject != "user-42" || claims.Email != "ada@example.com" || !claims.EmailVerified || claims.Name != "Ada" {
+		t.Errorf("Exchange() claims = %+v", claims)
+	}
+
+	_, err = provider.Exchange(ctx, code, verifier, "nonce-1")
+	if err == nil {
+		t.Error("Exchange() reusing a code should fail")
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	issuer, provider := newProvider(t)
+
+	verifier, _ := oidc.NewPKCEVerifier()
+	other, _ := oidc.NewPKCEVerifier()
+
+	code := signIn(t, issuer, provider, verifier, "nonce-1")
+	_, err := provider.Exchange(context.Background(), code, other, "nonce-1")
+	if err == nil {
+		t.Error("Exchange() with the wrong code verifier should fail")
+	}
+}
+
+func TestExchangeRejectsWrongNonce(t *testing.T) {
+	issuer, provider := newProvider(t)
+
+	verifier, _ := oidc.NewPKCEVerifier()
+	code := signIn(t, issuer, provider, verifier, "nonce-1")
+	_, err := provider.Exchange(context.Background(), code, verifier, "nonce-2")
+	if !errors.Is(err, oidc.ErrNonceMismatch) {
+		t.Errorf("Exchange() error = %v, want ErrNonceMismatch", err)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	issuer, provider := newProvider(t)
+	now := time.Now()
+
+	valid := jwt.MapClaims{
+		"iss":   issuer.URL,
+		"sub":   "user-42",
+		"aud":   "chirpy",
+		"exp":   now.Add(time.Minute).Unix(),
+		"nonce": "n",
+	}
+
+	tests := []struct {
+		name    string
+		change  func(jwt.MapClaims)
+		wantErr bool
+	}{
+		{name: "Valid", change: func(jwt.MapClaims) {}},
+		{name: "Other audience", change: func(c jwt.MapClaims) { c["aud"] = "someone-else" }, wantErr: true},
+		{name: "Other issuer", change: func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }, wantErr: true},
+		{name: "Expired", change: func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() }, wantErr: true},
+		{name: "No expiry", change: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: true},
+		{name: "No subject", change: func(c jwt.MapClaims) { delete(c, "sub") }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{}
+			for k, v := range valid {
+				claims[k] = v
+			}
+			tt.change(claims)
+
+			token, err := issuer.SignIDToken(claims)
+			if err != nil {
+				t.Fatalf("SignIDToken() error = %v", err)
+			}
+			_, err = provider.VerifyIDToken(context.Background(), token, "n")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyIDToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, valid).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	_, err := provider.VerifyIDToken(context.Background(), unsigned, "n")
+	if err == nil {
+		t.Error("VerifyIDToken() accepted an unsigned token")
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	_, provider := newProvider(t)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "st", "no", "verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge") != oidc.PKCEChallenge("verifier") || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("AuthCodeURL() PKCE params = %v", q)
+	}
+	if q.Get("scope") != "openid email profile" || q.Get("response_type") != "code" {
+		t.Errorf("AuthCodeURL() params = %v", q)
+	}
+}
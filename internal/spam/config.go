@@ -0,0 +1,149 @@
+package spam
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Default returns the pipeline used when no rules file is configured: a
+// repeated chirp or five chirps a minute slows the author down, and the
+// score needed to hold or reject a chirp takes more than one signal or a
+// stronger one.
+func Default() *Pipeline {
+	return &Pipeline{
+		Rules: []WeightedRule{
+			{Rule: DuplicateBody{Window: time.Hour}, Weight: 1},
+			{Rule: Velocity{Window: time.Minute, MaxPosts: 5}, Weight: 1},
+			{Rule: LinkDensity{MaxLinks: 2}, Weight: 1},
+			{Rule: AccountAge{MinAge: 24 * time.Hour}, Weight: 0.5},
+		},
+		Thresholds:  Thresholds{Throttle: 1, Hold: 2, Reject: 3},
+		ThrottleFor: time.Minute,
+	}
+}
+
+// fileFormat is the JSON layout of a rules file. Rules that are left out
+// are switched off:
+//
+//	{
+//	  "thresholds": {"throttle": 1, "hold": 2, "reject": 3},
+//	  "throttle_seconds": 60,
+//	  "rules": {
+//	    "duplicate_body": {"weight": 1, "window_seconds": 3600},
+//	    "velocity": {"weight": 1, "window_seconds": 60, "max_posts": 5},
+//	    "link_density": {"weight": 1, "max_links": 2},
+//	    "account_age": {"weight": 0.5, "min_age_seconds": 86400}
+//	  }
+//	}
+type fileFormat struct {
+	Thresholds struct {
+		Throttle float64 `json:"throttle"`
+		Hold     float64 `json:"hold"`
+		Reject   float64 `json:"reject"`
+	} `json:"thresholds"`
+	ThrottleSeconds int `json:"throttle_seconds"`
+	Rules           struct {
+		DuplicateBody *struct {
+			Weight        float64 `json:"weight"`
+			WindowSeconds int     `json:"window_seconds"`
+		} `json:"duplicate_body"`
+		Velocity *struct {
+			Weight        float64 `json:"weight"`
+			WindowSeconds int     `json:"window_seconds"`
+			MaxPosts      int     `json:"max_posts"`
+		} `json:"velocity"`
+		LinkDensity *struct {
+			Weight   float64 `json:"weight"`
+			MaxLinks int     `json:"max_links"`
+		} `json:"link_density"`
+		AccountAge *struct {
+			Weight        float64 `json:"weight"`
+			MinAgeSeconds int     `json:"min_age_seconds"`
+		} `json:"account_age"`
+	} `json:"rules"`
+}
+
+// Load reads a pipeline from the JSON rules file at path.
+func Load(path string) (*Pipeline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read parses a pipeline in the rules file format. Unknown rules and
+// fields are rejected so a typo doesn't silently switch a rule off.
+func Read(r io.Reader) (*Pipeline, error) {
+	var file fileFormat
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spam rules file: %w", err)
+	}
+
+	p := &Pipeline{
+		Thresholds: Thresholds{
+			Throttle: file.Thresholds.Throttle,
+			Hold:     file.Thresholds.Hold,
+			Reject:   file.Thresholds.Reject,
+		},
+		ThrottleFor: time.Duration(file.ThrottleSeconds) * time.Second,
+	}
+	if p.Thresholds.Throttle < 0 || p.Thresholds.Hold < 0 || p.Thresholds.Reject < 0 {
+		return nil, fmt.Errorf("thresholds can't be negative")
+	}
+	if p.Thresholds.Throttle > 0 && p.ThrottleFor <= 0 {
+		return nil, fmt.Errorf("throttle_seconds is required when throttling")
+	}
+
+	rules := file.Rules
+	if rule := rules.DuplicateBody; rule != nil {
+		if rule.WindowSeconds <= 0 {
+			return nil, fmt.Errorf("duplicate_body: window_seconds must be positive")
+		}
+		p.Rules = append(p.Rules, WeightedRule{
+			Rule:   DuplicateBody{Window: time.Duration(rule.WindowSeconds) * time.Second},
+			Weight: rule.Weight,
+		})
+	}
+	if rule := rules.Velocity; rule != nil {
+		if rule.WindowSeconds <= 0 || rule.MaxPosts <= 0 {
+			return nil, fmt.Errorf("velocity: window_seconds and max_posts must be positive")
+		}
+		p.Rules = append(p.Rules, WeightedRule{
+			Rule:   Velocity{Window: time.Duration(rule.WindowSeconds) * time.Second, MaxPosts: rule.MaxPosts},
+			Weight: rule.Weight,
+		})
+	}
+	if rule := rules.LinkDensity; rule != nil {
+		if rule.MaxLinks < 0 {
+			return nil, fmt.Errorf("link_density: max_links can't be negative")
+		}
+		p.Rules = append(p.Rules, WeightedRule{
+			Rule:   LinkDensity{MaxLinks: rule.MaxLinks},
+			Weight: rule.Weight,
+		})
+	}
+	if rule := rules.AccountAge; rule != nil {
+		if rule.MinAgeSeconds <= 0 {
+			return nil, fmt.Errorf("account_age: min_age_seconds must be positive")
+		}
+		p.Rules = append(p.Rules, WeightedRule{
+			Rule:   AccountAge{MinAge: time.Duration(rule.MinAgeSeconds) * time.Second},
+			Weight: rule.Weight,
+		})
+	}
+
+	for _, rule := range p.Rules {
+		if rule.Weight < 0 {
+			return nil, fmt.Errorf("%s: weight can't be negative", rule.Name())
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,56 @@
+package spam
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	p, err := Read(strings.NewReader(`{
+		"thresholds": {"throttle": 1, "reject": 2},
+		"throttle_seconds": 30,
+		"rules": {
+			"velocity": {"weight": 1, "window_seconds": 10, "max_posts": 2},
+			"account_age": {"weight": 0.25, "min_age_seconds": 3600}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	if len(p.Rules) != 2 || p.Rules[0].Name() != "velocity" || p.Rules[1].Weight != 0.25 {
+		t.Errorf("Rules = %+v", p.Rules)
+	}
+	if p.Thresholds != (Thresholds{Throttle: 1, Reject: 2}) || p.ThrottleFor != 30*time.Second {
+		t.Errorf("Thresholds = %+v, ThrottleFor = %v", p.Thresholds, p.ThrottleFor)
+	}
+	if p.Lookback() != 10*time.Second {
+		t.Errorf("Lookback() = %v", p.Lookback())
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"Unknown rule", `{"rules": {"captcha": {}}}`},
+		{"Unknown field", `{"rules": {"link_density": {"max_link": 1}}}`},
+		{"Negative weight", `{"rules": {"link_density": {"weight": -1}}}`},
+		{"Negative threshold", `{"thresholds": {"hold": -1}}`},
+		{"Throttle without duration", `{"thresholds": {"throttle": 1}}`},
+		{"Missing window", `{"rules": {"duplicate_body": {"weight": 1}}}`},
+		{"Missing max posts", `{"rules": {"velocity": {"weight": 1, "window_seconds": 60}}}`},
+		{"Not JSON", `rules`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.file))
+			if err == nil {
+				t.Error("Read() error = nil, want an error")
+			}
+		})
+	}
+}
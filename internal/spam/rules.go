@@ -0,0 +1,112 @@
+package spam
+
+import (
+	"strings"
+	"time"
+)
+
+// DuplicateBody scores one point for every chirp the author posted with
+// the same body, ignoring case and spacing, within Window.
+type DuplicateBody struct {
+	Window time.Duration
+}
+
+// Name -
+func (r DuplicateBody) Name() string { return "duplicate_body" }
+
+// Lookback -
+func (r DuplicateBody) Lookback() time.Duration { return r.Window }
+
+// Score -
+func (r DuplicateBody) Score(p Post) float64 {
+	body := normalize(p.Body)
+	duplicates := 0
+	for _, recent := range p.Recent {
+		if p.Now.Sub(recent.CreatedAt) <= r.Window && normalize(recent.Body) == body {
+			duplicates++
+		}
+	}
+	return float64(duplicates)
+}
+
+func normalize(body string) string {
+	return strings.Join(strings.Fields(strings.ToLower(body)), " ")
+}
+
+// Velocity scores one point for every MaxPosts chirps the author posted
+// within Window, so posting at twice the rate scores twice as much.
+type Velocity struct {
+	Window   time.Duration
+	MaxPosts int
+}
+
+// Name -
+func (r Velocity) Name() string { return "velocity" }
+
+// Lookback -
+func (r Velocity) Lookback() time.Duration { return r.Window }
+
+// Score -
+func (r Velocity) Score(p Post) float64 {
+	if r.MaxPosts <= 0 {
+		return 0
+	}
+	posts := 0
+	for _, recent := range p.Recent {
+		if p.Now.Sub(recent.CreatedAt) <= r.Window {
+			posts++
+		}
+	}
+	return float64(posts / r.MaxPosts)
+}
+
+// LinkDensity scores one point for every link beyond MaxLinks, and half a
+// point when links make up at least half of the chirp's words.
+type LinkDensity struct {
+	MaxLinks int
+}
+
+// Name -
+func (r LinkDensity) Name() string { return "link_density" }
+
+// Score -
+func (r LinkDensity) Score(p Post) float64 {
+	words := strings.Fields(p.Body)
+	links := 0
+	for _, word := range words {
+		if isLink(word) {
+			links++
+		}
+	}
+
+	score := 0.0
+	if links > r.MaxLinks {
+		score += float64(links - r.MaxLinks)
+	}
+	if links > 0 && links*2 >= len(words) {
+		score += 0.5
+	}
+	return score
+}
+
+func isLink(word string) bool {
+	word = strings.ToLower(word)
+	return strings.HasPrefix(word, "http://") || strings.HasPrefix(word, "https://") || strings.HasPrefix(word, "www.")
+}
+
+// AccountAge scores one point for accounts younger than MinAge. On its own
+// it shouldn't be enough to act; it makes other signals count for more.
+type AccountAge struct {
+	MinAge time.Duration
+}
+
+// Name -
+func (r AccountAge) Name() string { return "account_age" }
+
+// Score -
+func (r AccountAge) Score(p Post) float64 {
+	if p.Now.Sub(p.AuthorCreatedAt) < r.MinAge {
+		return 1
+	}
+	return 0
+}
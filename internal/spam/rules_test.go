@@ -0,0 +1,44 @@
+package spam
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRules(t *testing.T) {
+	now := time.Date(2025, 4, 18, 12, 0, 0, 0, time.UTC)
+	recent := []Recent{
+		{Body: "Buy   NOW", CreatedAt: now.Add(-10 * time.Second)},
+		{Body: "buy now", CreatedAt: now.Add(-30 * time.Minute)},
+		{Body: "buy now", CreatedAt: now.Add(-2 * time.Hour)},
+		{Body: "hello", CreatedAt: now.Add(-20 * time.Second)},
+		{Body: "hello again", CreatedAt: now.Add(-50 * time.Second)},
+	}
+
+	tests := []struct {
+		name string
+		rule Rule
+		post Post
+		want float64
+	}{
+		{"Duplicates within window", DuplicateBody{Window: time.Hour}, Post{Body: "buy now", Recent: recent, Now: now}, 2},
+		{"No duplicates", DuplicateBody{Window: time.Hour}, Post{Body: "something new", Recent: recent, Now: now}, 0},
+		{"Under velocity limit", Velocity{Window: time.Minute, MaxPosts: 5}, Post{Recent: recent, Now: now}, 0},
+		{"Over velocity limit", Velocity{Window: time.Minute, MaxPosts: 3}, Post{Recent: recent, Now: now}, 1},
+		{"Twice the velocity limit", Velocity{Window: time.Hour, MaxPosts: 2}, Post{Recent: recent, Now: now}, 2},
+		{"No links", LinkDensity{MaxLinks: 2}, Post{Body: "just words here"}, 0},
+		{"One link in a sentence", LinkDensity{MaxLinks: 2}, Post{Body: "look at https://example.com for the details"}, 0},
+		{"Bare link", LinkDensity{MaxLinks: 2}, Post{Body: "https://example.com"}, 0.5},
+		{"Too many links", LinkDensity{MaxLinks: 1}, Post{Body: "http://a.example www.b.example https://c.example and some words"}, 2.5},
+		{"New account", AccountAge{MinAge: 24 * time.Hour}, Post{AuthorCreatedAt: now.Add(-time.Hour), Now: now}, 1},
+		{"Old account", AccountAge{MinAge: 24 * time.Hour}, Post{AuthorCreatedAt: now.Add(-48 * time.Hour), Now: now}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Score(tt.post); got != tt.want {
+				t.Errorf("%s.Score() = %v, want %v", tt.rule.Name(), got, tt.want)
+			}
+		})
+	}
+}
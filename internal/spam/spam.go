@@ -0,0 +1,209 @@
+// Package spam scores new chirps against a set of rules and decides whether
+// to allow them, slow the author down, hold them for a moderator or reject
+// them outright.
+//
+// Every rule looks at one signal and returns a score, 0 when it sees
+// nothing suspicious. The pipeline adds up the weighted scores and compares
+// the total against its thresholds, so several weak signals together can
+// act where none would alone.
+package spam
+
+import (
+	"sync"
+	"time"
+)
+
+// Action is what to do with a chirp, in increasing order of severity.
+type Action int
+
+const (
+	// Allow -
+	Allow Action = iota
+	// Throttle turns the chirp away for now and asks the author to wait.
+	Throttle
+	// Hold stores the chirp hidden until a moderator reviews it.
+	Hold
+	// Reject -
+	Reject
+)
+
+func (a Action) String() string {
+	switch a {
+	case Allow:
+		return "allow"
+	case Throttle:
+		return "throttle"
+	case Hold:
+		return "hold"
+	case Reject:
+		return "reject"
+	}
+	return "unknown"
+}
+
+// Post is a chirp about to be created along with what rules need to know
+// about its author.
+type Post struct {
+	Body            string
+	AuthorCreatedAt time.Time
+	// Recent are the author's chirps from at least the pipeline's
+	// Lookback, in any order.
+	Recent []Recent
+	Now    time.Time
+}
+
+// Recent is one of the author's earlier chirps.
+type Recent struct {
+	Body      string
+	CreatedAt time.Time
+}
+
+// Rule scores one signal. Scores are unweighted and 0 when the rule sees
+// nothing suspicious.
+type Rule interface {
+	Name() string
+	Score(p Post) float64
+}
+
+// Windowed is implemented by rules that look at the author's recent
+// chirps, to say how far back they look.
+type Windowed interface {
+	Lookback() time.Duration
+}
+
+// WeightedRule is a rule and how much its score counts.
+type WeightedRule struct {
+	Rule
+	Weight float64
+}
+
+// Thresholds are the total scores at which each action kicks in. A zero
+// threshold never triggers.
+type Thresholds struct {
+	Throttle float64
+	Hold     float64
+	Reject   float64
+}
+
+// RuleScore is one rule's weighted contribution to a decision.
+type RuleScore struct {
+	Rule  string  `json:"rule"`
+	Score float64 `json:"score"`
+}
+
+// Decision is the outcome of evaluating a post.
+type Decision struct {
+	Action Action
+	Score  float64
+	// Rules lists the rules that scored above zero.
+	Rules []RuleScore
+}
+
+// Pipeline runs a post through its rules. It keeps metrics, so share one
+// pipeline rather than copying it.
+type Pipeline struct {
+	Rules      []WeightedRule
+	Thresholds Thresholds
+	// ThrottleFor is how long a throttled author is asked to wait.
+	ThrottleFor time.Duration
+
+	metrics metrics
+}
+
+// Lookback is how far back the author's chirps have to go to satisfy
+// every rule.
+func (p *Pipeline) Lookback() time.Duration {
+	var lookback time.Duration
+	for _, rule := range p.Rules {
+		if w, ok := rule.Rule.(Windowed); ok && w.Lookback() > lookback {
+			lookback = w.Lookback()
+		}
+	}
+	return lookback
+}
+
+// Evaluate scores post and picks the action for it.
+func (p *Pipeline) Evaluate(post Post) Decision {
+	decision := Decision{}
+	scores := make([]float64, len(p.Rules))
+	for i, rule := range p.Rules {
+		scores[i] = rule.Score(post) * rule.Weight
+		if scores[i] > 0 {
+			decision.Score += scores[i]
+			decision.Rules = append(decision.Rules, RuleScore{Rule: rule.Name(), Score: scores[i]})
+		}
+	}
+
+	switch t := p.Thresholds; {
+	case reached(decision.Score, t.Reject):
+		decision.Action = Reject
+	case reached(decision.Score, t.Hold):
+		decision.Action = Hold
+	case reached(decision.Score, t.Throttle):
+		decision.Action = Throttle
+	}
+
+	p.metrics.record(p.Rules, scores, decision.Action)
+	return decision
+}
+
+func reached(score, threshold float64) bool {
+	return threshold > 0 && score >= threshold
+}
+
+// RuleMetrics counts how one rule has scored since startup.
+type RuleMetrics struct {
+	Evaluated int64   `json:"evaluated"`
+	Triggered int64   `json:"triggered"`
+	ScoreSum  float64 `json:"score_sum"`
+}
+
+// Metrics is a snapshot of a pipeline's counters.
+type Metrics struct {
+	Rules   map[string]RuleMetrics `json:"rules"`
+	Actions map[string]int64       `json:"actions"`
+}
+
+type metrics struct {
+	mu      sync.Mutex
+	rules   map[string]RuleMetrics
+	actions map[Action]int64
+}
+
+func (m *metrics) record(rules []WeightedRule, scores []float64, action Action) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.rules == nil {
+		m.rules = map[string]RuleMetrics{}
+		m.actions = map[Action]int64{}
+	}
+	for i, rule := range rules {
+		rm := m.rules[rule.Name()]
+		rm.Evaluated++
+		if scores[i] > 0 {
+			rm.Triggered++
+			rm.ScoreSum += scores[i]
+		}
+		m.rules[rule.Name()] = rm
+	}
+	m.actions[action]++
+}
+
+// Metrics returns the pipeline's counters so far.
+func (p *Pipeline) Metrics() Metrics {
+	p.metrics.mu.Lock()
+	defer p.metrics.mu.Unlock()
+
+	snapshot := Metrics{
+		Rules:   map[string]RuleMetrics{},
+		Actions: map[string]int64{},
+	}
+	for _, rule := range p.Rules {
+		snapshot.Rules[rule.Name()] = p.metrics.rules[rule.Name()]
+	}
+	for _, action := range []Action{Allow, Throttle, Hold, Reject} {
+		snapshot.Actions[action.String()] = p.metrics.actions[action]
+	}
+	return snapshot
+}
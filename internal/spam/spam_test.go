@@ -0,0 +1,76 @@
+package spam
+
+import (
+	"testing"
+	"time"
+)
+
+// fixed always scores the same, to test the pipeline on its own.
+type fixed struct {
+	name  string
+	score float64
+}
+
+func (f fixed) Name() string         { return f.name }
+func (f fixed) Score(p Post) float64 { return f.score }
+
+func TestPipelineEvaluate(t *testing.T) {
+	thresholds := Thresholds{Throttle: 1, Hold: 2, Reject: 3}
+
+	tests := []struct {
+		name  string
+		rules []WeightedRule
+		want  Action
+	}{
+		{"Nothing suspicious", []WeightedRule{{fixed{"a", 0}, 1}}, Allow},
+		{"Weak signal", []WeightedRule{{fixed{"a", 1}, 0.5}}, Allow},
+		{"Weak signals add up", []WeightedRule{{fixed{"a", 1}, 0.5}, {fixed{"b", 1}, 0.5}}, Throttle},
+		{"Hold", []WeightedRule{{fixed{"a", 2}, 1}}, Hold},
+		{"Reject", []WeightedRule{{fixed{"a", 2}, 1}, {fixed{"b", 1}, 1}}, Reject},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Pipeline{Rules: tt.rules, Thresholds: thresholds}
+			if got := p.Evaluate(Post{}); got.Action != tt.want {
+				t.Errorf("Evaluate() = %+v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineDisabledThreshold(t *testing.T) {
+	p := &Pipeline{
+		Rules:      []WeightedRule{{fixed{"a", 10}, 1}},
+		Thresholds: Thresholds{Throttle: 1, Hold: 2},
+	}
+	if got := p.Evaluate(Post{}); got.Action != Hold {
+		t.Errorf("Evaluate() = %v, want hold when reject is disabled", got.Action)
+	}
+}
+
+func TestPipelineMetrics(t *testing.T) {
+	p := &Pipeline{
+		Rules:      []WeightedRule{{fixed{"quiet", 0}, 1}, {fixed{"loud", 1}, 2}},
+		Thresholds: Thresholds{Hold: 2},
+	}
+	p.Evaluate(Post{})
+	p.Evaluate(Post{})
+
+	m := p.Metrics()
+	if got := m.Rules["quiet"]; got.Evaluated != 2 || got.Triggered != 0 {
+		t.Errorf("quiet metrics = %+v", got)
+	}
+	if got := m.Rules["loud"]; got.Evaluated != 2 || got.Triggered != 2 || got.ScoreSum != 4 {
+		t.Errorf("loud metrics = %+v", got)
+	}
+	if m.Actions["hold"] != 2 || m.Actions["allow"] != 0 {
+		t.Errorf("action metrics = %v", m.Actions)
+	}
+}
+
+func TestPipelineLookback(t *testing.T) {
+	if got := Default().Lookback(); got != time.Hour {
+		t.Errorf("Default().Lookback() = %v, want 1h", got)
+	}
+}
@@ -0,0 +1,150 @@
+// Package subscription is the Chirpy Red subscription state machine:
+//
+//   - upgraded starts an active subscription, or a trial for users who have
+//     never subscribed
+//   - renewed starts a new active period from any state
+//   - payment_failed moves a live subscription to past_due
+//   - downgraded cancels it
+//
+// past_due and canceled subscriptions keep access until the current period
+// ends; any subscription whose period ends without a renewal expires.
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status -
+type Status string
+
+const (
+	// StatusTrialing -
+	StatusTrialing Status = "trialing"
+	// StatusActive -
+	StatusActive Status = "active"
+	// StatusPastDue means a payment failed; access continues until the
+	// period ends so the user has time to fix it.
+	StatusPastDue Status = "past_due"
+	// StatusCanceled means the user downgraded; access continues until the
+	// period they paid for ends.
+	StatusCanceled Status = "canceled"
+	// StatusExpired -
+	StatusExpired Status = "expired"
+)
+
+// Event is something that happened to a subscription at the payment
+// provider.
+type Event string
+
+const (
+	// EventUpgraded starts a subscription, or a trial when Trial is set.
+	EventUpgraded Event = "upgraded"
+	// EventRenewed -
+	EventRenewed Event = "renewed"
+	// EventPaymentFailed -
+	EventPaymentFailed Event = "payment_failed"
+	// EventDowngraded -
+	EventDowngraded Event = "downgraded"
+)
+
+// DefaultPeriod is used when the provider doesn't say when a period ends.
+const DefaultPeriod = 30 * 24 * time.Hour
+
+// ErrInvalidTransition -
+var ErrInvalidTransition = errors.New("invalid subscription transition")
+
+// State is a user's subscription. The zero State means they never had one.
+type State struct {
+	Status           Status
+	CurrentPeriodEnd time.Time
+	CanceledAt       time.Time
+}
+
+// Change describes an Event as the provider reported it.
+type Change struct {
+	Event Event
+	// PeriodEnd is when the new period ends for upgrades and renewals;
+	// now plus DefaultPeriod when zero.
+	PeriodEnd time.Time
+	// Trial starts a trial rather than a paid period on upgrade.
+	Trial bool
+}
+
+// Entitled reports whether the subscription gives access to Chirpy Red
+// at now.
+func (s State) Entitled(now time.Time) bool {
+	switch s.Status {
+	case StatusTrialing, StatusActive, StatusPastDue, StatusCanceled:
+		return now.Before(s.CurrentPeriodEnd)
+	}
+	return false
+}
+
+// Apply returns the state after change happens at now.
+func (s State) Apply(change Change, now time.Time) (State, error) {
+	periodEnd := change.PeriodEnd
+	if periodEnd.IsZero() {
+		periodEnd = now.Add(DefaultPeriod)
+	}
+
+	switch change.Event {
+	case EventUpgraded:
+		status := StatusActive
+		if change.Trial {
+			// a trial is only offered to users who have never subscribed
+			if s.Status != "" {
+				return s, s.invalid(change.Event)
+			}
+			status = StatusTrialing
+		}
+		return State{Status: status, CurrentPeriodEnd: periodEnd}, nil
+
+	case EventRenewed:
+		if s.Status == "" {
+			return s, s.invalid(change.Event)
+		}
+		return State{Status: StatusActive, CurrentPeriodEnd: periodEnd}, nil
+
+	case EventPaymentFailed:
+		switch s.Status {
+		case StatusTrialing, StatusActive, StatusPastDue:
+			s.Status = StatusPastDue
+			return s, nil
+		}
+		return s, s.invalid(change.Event)
+
+	case EventDowngraded:
+		switch s.Status {
+		case StatusTrialing, StatusActive, StatusPastDue:
+			s.Status = StatusCanceled
+			s.CanceledAt = now
+			return s, nil
+		case StatusCanceled, StatusExpired:
+			// already on the way out
+			return s, nil
+		}
+		return s, s.invalid(change.Event)
+	}
+
+	return s, fmt.Errorf("%w: unknown event %q", ErrInvalidTransition, change.Event)
+}
+
+// Expire returns the state once a lapsed subscription is swept up, and
+// whether anything changed.
+func (s State) Expire(now time.Time) (State, bool) {
+	if s.Status == "" || s.Status == StatusExpired || now.Before(s.CurrentPeriodEnd) {
+		return s, false
+	}
+	s.Status = StatusExpired
+	return s, true
+}
+
+func (s State) invalid(event Event) error {
+	from := string(s.Status)
+	if from == "" {
+		from = "no subscription"
+	}
+	return fmt.Errorf("%w: %s from %s", ErrInvalidTransition, event, from)
+}
@@ -0,0 +1,102 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApply(t *testing.T) {
+	now := time.Date(2025, 4, 14, 12, 0, 0, 0, time.UTC)
+	periodEnd := now.Add(30 * 24 * time.Hour)
+	active := State{Status: StatusActive, CurrentPeriodEnd: periodEnd}
+
+	tests := []struct {
+		name    string
+		from    State
+		change  Change
+		want    Status
+		wantErr bool
+	}{
+		{"Upgrade new user", State{}, Change{Event: EventUpgraded}, StatusActive, false},
+		{"Start trial", State{}, Change{Event: EventUpgraded, Trial: true}, StatusTrialing, false},
+		{"No second trial", State{Status: StatusExpired}, Change{Event: EventUpgraded, Trial: true}, StatusExpired, true},
+		{"Resubscribe after expiry", State{Status: StatusExpired}, Change{Event: EventUpgraded}, StatusActive, false},
+		{"Trial converts on renewal", State{Status: StatusTrialing}, Change{Event: EventRenewed}, StatusActive, false},
+		{"Renewal recovers past due", State{Status: StatusPastDue}, Change{Event: EventRenewed}, StatusActive, false},
+		{"Renewal without subscription", State{}, Change{Event: EventRenewed}, "", true},
+		{"Payment fails", active, Change{Event: EventPaymentFailed}, StatusPastDue, false},
+		{"Payment fails after cancel", State{Status: StatusCanceled}, Change{Event: EventPaymentFailed}, StatusCanceled, true},
+		{"Downgrade", active, Change{Event: EventDowngraded}, StatusCanceled, false},
+		{"Downgrade twice", State{Status: StatusCanceled}, Change{Event: EventDowngraded}, StatusCanceled, false},
+		{"Downgrade without subscription", State{}, Change{Event: EventDowngraded}, "", true},
+		{"Unknown event", active, Change{Event: "refunded"}, StatusActive, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.from.Apply(tt.change, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("Apply() error = %v, want ErrInvalidTransition", err)
+			}
+			if got.Status != tt.want {
+				t.Errorf("Apply() status = %q, want %q", got.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPeriods(t *testing.T) {
+	now := time.Date(2025, 4, 14, 12, 0, 0, 0, time.UTC)
+	periodEnd := now.Add(7 * 24 * time.Hour)
+
+	s, err := State{}.Apply(Change{Event: EventUpgraded, PeriodEnd: periodEnd}, now)
+	if err != nil || !s.CurrentPeriodEnd.Equal(periodEnd) {
+		t.Fatalf("Apply() = %+v, %v; want period ending %v", s, err, periodEnd)
+	}
+
+	s, err = s.Apply(Change{Event: EventPaymentFailed}, now)
+	if err != nil || !s.CurrentPeriodEnd.Equal(periodEnd) {
+		t.Fatalf("payment failure changed period to %v, %v", s.CurrentPeriodEnd, err)
+	}
+
+	s, err = s.Apply(Change{Event: EventRenewed}, now)
+	if err != nil || !s.CurrentPeriodEnd.Equal(now.Add(DefaultPeriod)) {
+		t.Fatalf("renewal without period end gave %v, %v", s.CurrentPeriodEnd, err)
+	}
+
+	s, err = s.Apply(Change{Event: EventDowngraded}, now)
+	if err != nil || !s.CanceledAt.Equal(now) {
+		t.Fatalf("downgrade gave canceled_at %v, %v", s.CanceledAt, err)
+	}
+}
+
+func TestEntitledAndExpire(t *testing.T) {
+	now := time.Date(2025, 4, 14, 12, 0, 0, 0, time.UTC)
+	canceled := State{Status: StatusCanceled, CurrentPeriodEnd: now.Add(time.Hour)}
+
+	if !canceled.Entitled(now) {
+		t.Error("canceled subscription should keep access until its period ends")
+	}
+	if _, changed := canceled.Expire(now); changed {
+		t.Error("Expire() expired a subscription before its period ended")
+	}
+
+	later := now.Add(2 * time.Hour)
+	if canceled.Entitled(later) {
+		t.Error("canceled subscription still entitled after its period ended")
+	}
+	expired, changed := canceled.Expire(later)
+	if !changed || expired.Status != StatusExpired {
+		t.Errorf("Expire() = %q, %v; want expired", expired.Status, changed)
+	}
+	if _, changed := expired.Expire(later); changed {
+		t.Error("Expire() changed an expired subscription")
+	}
+	if (State{}).Entitled(now) {
+		t.Error("no subscription should not be entitled")
+	}
+}
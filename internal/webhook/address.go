@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// ErrPrivateAddress is returned for endpoints that resolve to an address
+// on Chirpy's own network rather than the public internet.
+var ErrPrivateAddress = errors.New("endpoint resolves to a private address")
+
+// reservedPrefixes are the ranges not caught by the netip.Addr predicates
+// that still can't be reached from the public internet.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// PublicAddr reports whether addr is somewhere deliveries may be sent:
+// not loopback, private, link-local (which covers cloud metadata
+// services), multicast or otherwise reserved.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckHost resolves host and returns ErrPrivateAddress if any of its
+// addresses isn't public.
+func CheckHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !PublicAddr(addr) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// dialPublicOnly is a net.Dialer Control hook that refuses connections to
+// addresses PublicAddr rejects. It runs after DNS resolution, so a host
+// that passed CheckHost at registration can't later be pointed inward.
+func dialPublicOnly(network, address string, c syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("unexpected dial address %q: %w", address, err)
+	}
+	if !PublicAddr(addrPort.Addr()) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Headers sent with every outbound delivery.
+const (
+	SignatureHeader = "Chirpy-Signature"
+	EventHeader     = "Chirpy-Event"
+	DeliveryHeader  = "Chirpy-Delivery"
+)
+
+// Delivery is one signed POST to a subscriber.
+type Delivery struct {
+	ID        string
+	URL       string
+	Secret    string
+	EventType string
+	Body      []byte
+}
+
+// Result describes what happened to a delivery attempt. Err is set for
+// anything other than a 2xx response; StatusCode is 0 when no response
+// came back at all.
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// Sender posts deliveries to subscribers.
+type Sender struct {
+	Client *http.Client
+}
+
+// NewSender returns a Sender with a timeout that doesn't follow redirects,
+// so an endpoint can't bounce deliveries somewhere it wasn't registered
+// for. Unless allowPrivate is set it also refuses to connect to anything
+// but public addresses, and ignores proxy settings so that check applies
+// to the endpoint itself.
+func NewSender(timeout time.Duration, allowPrivate bool) Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer.Control = dialPublicOnly
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+
+	return Sender{
+		Client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Send makes one attempt at d, signed at now.
+func (s Sender) Send(ctx context.Context, d Delivery, now time.Time) Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return Result{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(SignatureHeader, Sign(d.Body, now, d.Secret))
+
+	start := time.Now()
+	resp, err := s.Client.Do(req)
+	duration := time.Since(start)
+	if err != nil {
+		return Result{Duration: duration, Err: err}
+	}
+	defer resp.Body.Close()
+	// drain a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	result := Result{StatusCode: resp.StatusCode, Duration: duration}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.Err = fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return result
+}
+
+// RetryDelay returns how long to wait before the next attempt after
+// attempts failures: 30 seconds, doubling up to six hours.
+func RetryDelay(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= 6*time.Hour {
+			return 6 * time.Hour
+		}
+	}
+	return delay
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSenderSend(t *testing.T) {
+	body := []byte(`{"type":"chirp.created"}`)
+	var gotErr error
+	var gotEvent, gotDelivery string
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dat, _ := io.ReadAll(r.Body)
+		gotErr = Verifier{Secrets: []string{"endpoint-secret"}}.Verify(r.Header.Get(SignatureHeader), dat, time.Now())
+		gotEvent = r.Header.Get(EventHeader)
+		gotDelivery = r.Header.Get(DeliveryHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	result := NewSender(5*time.Second, true).Send(context.Background(), Delivery{
+		ID:        "delivery-1",
+		URL:       receiver.URL,
+		Secret:    "endpoint-secret",
+		EventType: "chirp.created",
+		Body:      body,
+	}, time.Now())
+
+	if result.Err != nil || result.StatusCode != http.StatusNoContent {
+		t.Fatalf("Send() = %+v", result)
+	}
+	if gotErr != nil {
+		t.Errorf("receiver couldn't verify signature: %v", gotErr)
+	}
+	if gotEvent != "chirp.created" || gotDelivery != "delivery-1" {
+		t.Errorf("receiver got event %q delivery %q", gotEvent, gotDelivery)
+	}
+}
+
+func TestSenderSendFailures(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, failing.URL, http.StatusFound)
+	}))
+	defer redirecting.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL := closed.URL
+	closed.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+	}{
+		{"Server error", failing.URL, http.StatusInternalServerError},
+		{"Redirect not followed", redirecting.URL, http.StatusFound},
+		{"Timeout", slow.URL, 0},
+		{"Connection refused", closedURL, 0},
+	}
+
+	sender := NewSender(50*time.Millisecond, true)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sender.Send(context.Background(), Delivery{URL: tt.url, Secret: "s", Body: []byte("{}")}, time.Now())
+			if result.Err == nil {
+				t.Fatal("Send() error = nil, want an error")
+			}
+			if result.StatusCode != tt.wantStatus {
+				t.Errorf("Send() status = %d, want %d", result.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSenderSendPrivateAddress(t *testing.T) {
+	called := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer receiver.Close()
+
+	result := NewSender(5*time.Second, false).Send(context.Background(), Delivery{URL: receiver.URL, Secret: "s", Body: []byte("{}")}, time.Now())
+	if !errors.Is(result.Err, ErrPrivateAddress) {
+		t.Errorf("Send() error = %v, want ErrPrivateAddress", result.Err)
+	}
+	if called {
+		t.Error("receiver on a loopback address was called")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{20, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := RetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("RetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	now := time.Unix(1700000000, 0)
+	verifier := Verifier{Secrets: []string{"old-secret", "new-secret"}}
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		wantErr error
+	}{
+		{
+			name:   "Signed with current secret",
+			header: Sign(body, now, "new-secret"),
+			body:   body,
+		},
+		{
+			name:   "Signed with secret being rotated out",
+			header: Sign(body, now, "old-secret"),
+			body:   body,
+		},
+		{
+			name:   "Sender rotating, one signature matches",
+			header: Sign(body, now, "newer-secret", "new-secret"),
+			body:   body,
+		},
+		{
+			name:   "Within tolerance",
+			header: Sign(body, now.Add(-4*time.Minute), "new-secret"),
+			body:   body,
+		},
+		{
+			name:    "Unknown secret",
+			header:  Sign(body, now, "wrong-secret"),
+			body:    body,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Tampered body",
+			header:  Sign(body, now, "new-secret"),
+			body:    []byte(`{"event":"user.upgraded","data":{"user_id":"00000000-0000-0000-0000-000000000000"}}`),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Replayed",
+			header:  Sign(body, now.Add(-6*time.Minute), "new-secret"),
+			body:    body,
+			wantErr: ErrStaleSignature,
+		},
+		{
+			name:    "Future timestamp",
+			header:  Sign(body, now.Add(6*time.Minute), "new-secret"),
+			body:    body,
+			wantErr: ErrStaleSignature,
+		},
+		{
+			name:    "Missing",
+			header:  "",
+			body:    body,
+			wantErr: ErrMissingSignature,
+		},
+		{
+			name:    "No signature",
+			header:  "t=1700000000",
+			body:    body,
+			wantErr: ErrMalformedSignature,
+		},
+		{
+			name:    "Not hex",
+			header:  "t=1700000000,v1=zz",
+			body:    body,
+			wantErr: ErrMalformedSignature,
+		},
+		{
+			name:    "Static API key",
+			header:  "ApiKey f271c81ff7084ee5b99a5091b42d486e",
+			body:    body,
+			wantErr: ErrMalformedSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifier.Verify(tt.header, tt.body, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyTolerance(t *testing.T) {
+	body := []byte("{}")
+	now := time.Unix(1700000000, 0)
+	header := Sign(body, now.Add(-time.Minute), "secret")
+
+	err := Verifier{Secrets: []string{"secret"}, Tolerance: 30 * time.Second}.Verify(header, body, now)
+	if !errors.Is(err, ErrStaleSignature) {
+		t.Errorf("Verify() error = %v, want ErrStaleSignature", err)
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	got, err := Timestamp(Sign([]byte("{}"), time.Unix(1700000000, 0), "secret"))
+	if err != nil || got != "1700000000" {
+		t.Errorf("Timestamp() = %q, %v, want 1700000000", got, err)
+	}
+
+	_, err = Timestamp("v1=00")
+	if !errors.Is(err, ErrMalformedSignature) {
+		t.Errorf("Timestamp() error = %v, want ErrMalformedSignature", err)
+	}
+}
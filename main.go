@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
@@ -9,7 +10,13 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/blob"
 	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/entitlements"
+	"github.com/iamjoona/chippy/internal/mail"
+	"github.com/iamjoona/chippy/internal/spam"
+	"github.com/iamjoona/chippy/internal/webhook"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -17,33 +24,84 @@ import (
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	conn           *sql.DB
+	mailer         mail.Mailer
+	blobs          blob.Store
+	urlSigner      blob.URLSigner
+	baseURL        string
 	platform       string
 	jwtSecret      string
-	polkaApiKey    string
+	polkaWebhooks  webhook.Verifier
+	entitlements   entitlements.Catalog
+	spam           *spam.Pipeline
+	adminEmail     string
+	passwordHasher auth.PasswordHasher
+	passwordPolicy auth.PasswordPolicy
+	registration   registrationMode
+	oidcProviders  map[string]*oidcProvider
+	// accountDeletionGrace is how long a deleted account can still be
+	// restored by logging in before it is purged.
+	accountDeletionGrace time.Duration
+	// dummyPasswordHash is checked against when a login names an unknown
+	// email, so it costs as much as checking a real password.
+	dummyPasswordHash string
 }
 
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	Email          string    `json:"email"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	HashedPassword string    `json:"-"`
-	Token          string    `json:"token"`
-	RefreshToken   string    `json:"refresh_token"`
-	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ID             uuid.UUID  `json:"id"`
+	Email          string     `json:"email"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	HashedPassword string     `json:"-"`
+	Token          string     `json:"token"`
+	RefreshToken   string     `json:"refresh_token"`
+	IsChirpyRed    bool       `json:"is_chirpy_red"`
+	Role           string     `json:"role"`
+	EmailVerified  bool       `json:"email_verified"`
+	PendingEmail   string     `json:"pending_email,omitempty"`
+	Handle         string     `json:"handle,omitempty"`
+	DisplayName    string     `json:"display_name,omitempty"`
+	Bio            string     `json:"bio,omitempty"`
+	AvatarURL      string     `json:"avatar_url,omitempty"`
+	InvitedBy      *uuid.UUID `json:"invited_by,omitempty"`
+}
+
+func userFromDB(dbUser database.User) User {
+	user := User{
+		ID:            dbUser.ID,
+		Email:         dbUser.Email,
+		CreatedAt:     dbUser.CreatedAt,
+		UpdatedAt:     dbUser.UpdatedAt,
+		IsChirpyRed:   dbUser.IsChirpyRed,
+		Role:          dbUser.Role,
+		EmailVerified: dbUser.EmailVerifiedAt.Valid,
+		PendingEmail:  dbUser.PendingEmail.String,
+		Handle:        dbUser.Handle.String,
+		DisplayName:   dbUser.DisplayName.String,
+		Bio:           dbUser.Bio.String,
+		AvatarURL:     dbUser.AvatarUrl.String,
+	}
+	if dbUser.InvitedBy.Valid {
+		user.InvitedBy = &dbUser.InvitedBy.UUID
+	}
+	return user
 }
 
 type createUserRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	InviteCode string `json:"invite_code"`
 }
 
 type Chirp struct {
 	ID        uuid.UUID `json:"id"`
-	Body      string    `json:"body"`
+	Body      string    `json:"body,omitempty"`
 	UserID    uuid.UUID `json:"user_id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Muted is set on chirps collapsed by the caller's mute rules, which
+	// come without a body.
+	Muted bool `json:"muted,omitempty"`
 }
 
 type createChirpRequest struct {
@@ -64,9 +122,19 @@ func main() {
 		log.Fatal("JWT_SECRET environment variable is required")
 	}
 
-	polkaApiKey := os.Getenv("POLKA_KEY")
-	if os.Getenv("POLKA_KEY") == "" {
-		log.Fatal("POLKA_KEY environment variable is required")
+	polkaWebhooks, err := polkaWebhookVerifierFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid Polka webhook configuration: %v", err)
+	}
+
+	entitlementCatalog, err := entitlementsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid entitlements configuration: %v", err)
+	}
+
+	spamPipeline, err := spamPipelineFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid spam rules configuration: %v", err)
 	}
 
 	db, err := sql.Open("postgres", dbURL)
@@ -78,13 +146,118 @@ func main() {
 
 	dbQueries := database.New(db)
 
+	passwordHasher, err := passwordHasherFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid password hashing configuration: %v", err)
+	}
+
+	passwordPolicy, err := passwordPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid password policy configuration: %v", err)
+	}
+	if passwordPolicy.Breached != nil {
+		log.Printf("Loaded %d breached password hashes", passwordPolicy.Breached.Len())
+	}
+
+	dummyPasswordHash, err := passwordHasher.Hash(uuid.NewString())
+	if err != nil {
+		log.Fatalf("Error hashing dummy password: %v", err)
+	}
+
+	registration, err := registrationModeFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid registration configuration: %v", err)
+	}
+
+	baseURL := envDefault("APP_BASE_URL", "http://localhost:"+port)
+
+	oidcProviders, err := oidcProvidersFromEnv(baseURL)
+	if err != nil {
+		log.Fatalf("Invalid OIDC configuration: %v", err)
+	}
+
+	adminEmail := os.Getenv("ADMIN_EMAIL")
+	if adminEmail != "" {
+		adminEmail, err = auth.NormalizeEmail(adminEmail)
+		if err != nil {
+			log.Fatalf("Invalid ADMIN_EMAIL: %v", err)
+		}
+	}
+
+	graceDays, err := envInt("ACCOUNT_DELETION_GRACE_DAYS", 30)
+	if err != nil {
+		log.Fatalf("Invalid account deletion configuration: %v", err)
+	}
+	if graceDays < 0 {
+		log.Fatal("ACCOUNT_DELETION_GRACE_DAYS can't be negative")
+	}
+
+	urlSigningKey, err := urlSigningKeyFromEnv(jwtSecret)
+	if err != nil {
+		log.Fatalf("Invalid URL signing configuration: %v", err)
+	}
+
+	mailFrom := envDefault("MAIL_FROM", "chirpy@localhost")
+	mailSender, err := mailSenderFromEnv(mailFrom)
+	if err != nil {
+		log.Fatalf("Invalid mail configuration: %v", err)
+	}
+
+	mailDispatcher := &mail.Dispatcher{
+		DB:          dbQueries,
+		Sender:      mailSender,
+		Interval:    10 * time.Second,
+		BatchSize:   50,
+		MaxAttempts: 10,
+	}
+	go mailDispatcher.Run(context.Background())
+
 	apiCfg := apiConfig{
 		fileserverHits: atomic.Int32{},
 		db:             dbQueries,
+		conn:           db,
+		mailer:         mail.NewOutbox(dbQueries),
+		blobs:          blob.LocalStore{Dir: envDefault("BLOB_DIR", "blobs")},
+		urlSigner:      blob.URLSigner{Secret: urlSigningKey},
+		baseURL:        baseURL,
 		platform:       platform,
 		jwtSecret:      jwtSecret,
-		polkaApiKey:    polkaApiKey,
+		polkaWebhooks:  polkaWebhooks,
+		entitlements:   entitlementCatalog,
+		spam:           spamPipeline,
+		adminEmail:     adminEmail,
+		passwordHasher: passwordHasher,
+		passwordPolicy: passwordPolicy,
+		registration:   registration,
+		oidcProviders:  oidcProviders,
+
+		accountDeletionGrace: time.Duration(graceDays) * 24 * time.Hour,
+		dummyPasswordHash:    dummyPasswordHash,
+	}
+
+	err = apiCfg.bootstrapAdmin(context.Background())
+	if err != nil {
+		log.Fatalf("Error bootstrapping admin: %v", err)
+	}
+
+	purger := &accountPurger{cfg: &apiCfg, Interval: time.Hour}
+	go purger.Run(context.Background())
+
+	exportWorker := &dataExportWorker{cfg: &apiCfg, Interval: 30 * time.Second}
+	go exportWorker.Run(context.Background())
+
+	expirer := &subscriptionExpirer{cfg: &apiCfg, Interval: 10 * time.Minute}
+	go expirer.Run(context.Background())
+
+	webhookSender := &webhookDispatcher{
+		cfg:          &apiCfg,
+		Sender:       webhook.NewSender(10*time.Second, apiCfg.platform == "dev"),
+		Interval:     15 * time.Second,
+		BatchSize:    50,
+		MaxAttempts:  10,
+		DisableAfter: 15,
 	}
+	go webhookSender.Run(context.Background())
 
 	mux := http.NewServeMux()
 	fsHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot))))
@@ -96,14 +269,84 @@ func main() {
 	mux.HandleFunc("GET /api/chirps", apiCfg.getChirpsHandler)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.getSingleChirpHandler)
 	mux.HandleFunc("POST /api/login", apiCfg.loginHandler)
+	mux.HandleFunc("POST /api/login/magic", apiCfg.requestMagicLinkHandler)
+	mux.HandleFunc("POST /api/login/magic/exchange", apiCfg.exchangeMagicLinkHandler)
 	mux.HandleFunc("POST /api/refresh", apiCfg.refreshHandler)
 	mux.HandleFunc("POST /api/revoke", apiCfg.revokeHandler)
 	mux.HandleFunc("PUT /api/users", apiCfg.updateUserHandler)
+	mux.HandleFunc("GET /api/auth/oidc/{provider}/login", apiCfg.oidcLoginHandler)
+	mux.HandleFunc("POST /api/auth/oidc/{provider}/link", apiCfg.oidcLinkHandler)
+	mux.HandleFunc("GET /api/auth/oidc/{provider}/callback", apiCfg.oidcCallbackHandler)
+	mux.HandleFunc("GET /api/users/me/identities", apiCfg.listIdentitiesHandler)
+	mux.HandleFunc("GET /api/users/me/entitlements", apiCfg.getEntitlementsHandler)
+	mux.HandleFunc("POST /api/users/me/mutes", apiCfg.createMuteRuleHandler)
+	mux.HandleFunc("GET /api/users/me/mutes", apiCfg.listMuteRulesHandler)
+	mux.HandleFunc("DELETE /api/users/me/mutes/{muteID}", apiCfg.deleteMuteRuleHandler)
+	mux.HandleFunc("DELETE /api/users/me/identities/{identityID}", apiCfg.unlinkIdentityHandler)
+	mux.HandleFunc("PATCH /api/users/me", apiCfg.patchMeHandler)
+	mux.HandleFunc("DELETE /api/users/me", apiCfg.deleteMeHandler)
+	mux.HandleFunc("GET /api/users/{handle}", apiCfg.getProfileHandler)
+	mux.HandleFunc("GET /api/handles/{handle}", apiCfg.handleAvailabilityHandler)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirpHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", apiCfg.reportChirpHandler)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.userUpgradeHandler)
+	mux.HandleFunc("POST /api/exports", apiCfg.createDataExportHandler)
+	mux.HandleFunc("GET /api/exports/{exportID}", apiCfg.getDataExportHandler)
+	mux.HandleFunc("GET /api/exports/{exportID}/download", apiCfg.downloadDataExportHandler)
+	mux.HandleFunc("POST /api/invites", apiCfg.createInviteHandler)
+	mux.HandleFunc("GET /api/invites", apiCfg.listInvitesHandler)
+	mux.HandleFunc("DELETE /api/invites/{inviteID}", apiCfg.revokeInviteHandler)
+	mux.HandleFunc("POST /api/tokens", apiCfg.createTokenHandler)
+	mux.HandleFunc("GET /api/tokens", apiCfg.listTokensHandler)
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.revokeTokenHandler)
+	mux.HandleFunc("POST /api/webhooks/endpoints", apiCfg.createWebhookEndpointHandler)
+	mux.HandleFunc("GET /api/webhooks/endpoints", apiCfg.listWebhookEndpointsHandler)
+	mux.HandleFunc("DELETE /api/webhooks/endpoints/{endpointID}", apiCfg.deleteWebhookEndpointHandler)
+	mux.HandleFunc("POST /api/webhooks/endpoints/{endpointID}/enable", apiCfg.enableWebhookEndpointHandler)
+	mux.HandleFunc("GET /api/webhooks/endpoints/{endpointID}/deliveries", apiCfg.listWebhookDeliveriesHandler)
+	mux.HandleFunc("GET /api/webhooks/endpoints/{endpointID}/deliveries/{deliveryID}", apiCfg.getWebhookDeliveryHandler)
+	mux.HandleFunc("POST /api/oauth/clients", apiCfg.createOAuthClientHandler)
+	mux.HandleFunc("GET /api/oauth/clients", apiCfg.listOAuthClientsHandler)
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.deleteOAuthClientHandler)
+	mux.HandleFunc("GET /api/oauth/grants", apiCfg.listOAuthGrantsHandler)
+	mux.HandleFunc("DELETE /api/oauth/grants/{clientID}", apiCfg.revokeOAuthGrantHandler)
+	mux.HandleFunc("GET /oauth/authorize", apiCfg.oauthAuthorizeHandler)
+	mux.HandleFunc("POST /oauth/authorize", apiCfg.oauthApproveHandler)
+	mux.HandleFunc("POST /oauth/token", apiCfg.oauthTokenHandler)
+	mux.HandleFunc("POST /api/password/forgot", apiCfg.forgotPasswordHandler)
+	mux.HandleFunc("POST /api/password/reset", apiCfg.resetPasswordHandler)
+	mux.HandleFunc("POST /api/email/verify", apiCfg.verifyEmailHandler)
+	mux.HandleFunc("POST /api/email/verify/resend", apiCfg.resendVerificationHandler)
 
-	mux.HandleFunc("POST /admin/reset", apiCfg.HandlerReset)
-	mux.HandleFunc("/admin/metrics", apiCfg.HandlerMetrics)
+	mux.Handle("POST /admin/reset", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.HandlerReset), auth.RoleAdmin))
+	mux.Handle("/admin/metrics", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.HandlerMetrics), auth.RoleAdmin))
+	mux.Handle("PUT /admin/users/{userID}/role", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.setUserRoleHandler), auth.RoleAdmin))
+	mux.Handle("GET /admin/users/{userID}/invitees", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminListInviteesHandler), auth.RoleAdmin))
+	mux.Handle("POST /admin/invites", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminCreateInviteHandler), auth.RoleAdmin))
+	mux.Handle("GET /admin/invites", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminListInvitesHandler), auth.RoleAdmin))
+	mux.Handle("DELETE /admin/invites/{inviteID}", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminRevokeInviteHandler), auth.RoleAdmin))
+	mux.Handle("GET /admin/webhooks/events", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminListWebhookEventsHandler), auth.RoleAdmin))
+	mux.Handle("GET /admin/webhooks/events/{eventID}", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminGetWebhookEventHandler), auth.RoleAdmin))
+	mux.Handle("POST /admin/webhooks/events/{eventID}/replay", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminReplayWebhookEventHandler), auth.RoleAdmin))
+	mux.Handle("POST /admin/users/{userID}/restrictions", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminRestrictUserHandler), auth.RoleAdmin))
+	mux.Handle("GET /admin/users/{userID}/restrictions", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminListUserRestrictionsHandler), auth.RoleAdmin))
+	mux.Handle("GET /admin/restrictions", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminListActiveRestrictionsHandler), auth.RoleAdmin))
+	mux.Handle("DELETE /admin/restrictions/{restrictionID}", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminLiftRestrictionHandler), auth.RoleAdmin))
+	mux.Handle("GET /admin/reports", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.listReportsHandler), auth.RoleModerator, auth.RoleAdmin))
+	mux.Handle("GET /admin/reports/{reportID}", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.getReportHandler), auth.RoleModerator, auth.RoleAdmin))
+	mux.Handle("PUT /admin/reports/{reportID}/assignee", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.assignReportHandler), auth.RoleModerator, auth.RoleAdmin))
+	mux.Handle("POST /admin/reports/{reportID}/resolve", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.resolveReportHandler), auth.RoleModerator, auth.RoleAdmin))
+	mux.Handle("GET /admin/spam/holds", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.listSpamHoldsHandler), auth.RoleModerator, auth.RoleAdmin))
+	mux.Handle("POST /admin/spam/holds/{chirpID}/approve", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.approveSpamHoldHandler), auth.RoleModerator, auth.RoleAdmin))
+	mux.Handle("POST /admin/spam/holds/{chirpID}/reject", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.rejectSpamHoldHandler), auth.RoleModerator, auth.RoleAdmin))
+	mux.Handle("GET /admin/spam/metrics", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.spamMetricsHandler), auth.RoleAdmin))
+	mux.Handle("GET /admin/moderation/actions", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.listModerationActionsHandler), auth.RoleModerator, auth.RoleAdmin))
+	mux.Handle("POST /admin/webhooks/endpoints", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminCreateWebhookEndpointHandler), auth.RoleAdmin))
+	mux.Handle("GET /admin/webhooks/endpoints", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminListWebhookEndpointsHandler), auth.RoleAdmin))
+	mux.Handle("DELETE /admin/webhooks/endpoints/{endpointID}", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminDeleteWebhookEndpointHandler), auth.RoleAdmin))
+	mux.Handle("POST /admin/webhooks/endpoints/{endpointID}/enable", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminEnableWebhookEndpointHandler), auth.RoleAdmin))
+	mux.Handle("GET /admin/webhooks/endpoints/{endpointID}/deliveries", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminListWebhookDeliveriesHandler), auth.RoleAdmin))
+	mux.Handle("GET /admin/webhooks/endpoints/{endpointID}/deliveries/{deliveryID}", apiCfg.middlewareRequireRole(http.HandlerFunc(apiCfg.adminGetWebhookDeliveryHandler), auth.RoleAdmin))
 
 	srv := &http.Server{
 		Addr:    ":" + port,
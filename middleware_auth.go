@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+)
+
+var errMissingScope = errors.New("token is missing required scope")
+
+var errAccountDeleted = errors.New("account is scheduled for deletion")
+
+var errGrantMismatch = errors.New("token does not match its grant")
+
+// principal is the caller resolved from a request's bearer token.
+type principal struct {
+	UserID        uuid.UUID
+	Role          auth.Role
+	EmailVerified bool
+	IsChirpyRed   bool
+	// Scopes is nil for a regular login session, which is not restricted.
+	Scopes []auth.Scope
+	// TokenID is set when the caller used a personal access token.
+	TokenID uuid.NullUUID
+	// GrantID is set when the caller is an OAuth client acting for the user.
+	GrantID uuid.NullUUID
+	// AuthTime is when a login session last proved the user's identity;
+	// it is zero for personal access tokens and OAuth clients.
+	AuthTime time.Time
+}
+
+func (p principal) hasScope(scope auth.Scope) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// viewer identifies the caller to queries that show some content only to
+// its author; it is null for anonymous callers.
+func (p principal) viewer() uuid.NullUUID {
+	return uuid.NullUUID{UUID: p.UserID, Valid: p.UserID != uuid.Nil}
+}
+
+func (p principal) isSession() bool {
+	return !p.TokenID.Valid && !p.GrantID.Valid
+}
+
+// authenticatedWithin reports whether the caller logged in recently enough
+// to make sensitive changes without re-entering their password.
+func (p principal) authenticatedWithin(d time.Duration) bool {
+	return p.isSession() && time.Since(p.AuthTime) < d
+}
+
+func (p principal) hasRole(roles ...auth.Role) bool {
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalContextKey struct{}
+
+// principalFromContext returns the caller stored by middlewareRequireRole.
+func principalFromContext(ctx context.Context) (principal, bool) {
+	p, ok := ctx.Value(principalContextKey{}).(principal)
+	return p, ok
+}
+
+// authenticate resolves the bearer token on a request, accepting access
+// JWTs, including those issued to OAuth clients, and personal access tokens.
+func (cfg *apiConfig) authenticate(r *http.Request) (principal, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return principal{}, err
+	}
+
+	if !auth.IsPersonalAccessToken(token) {
+		userID, claims, err := auth.ParseAccessToken(token, cfg.jwtSecret)
+		if err != nil {
+			return principal{}, err
+		}
+		if claims.IsDelegated() {
+			return cfg.delegatedPrincipal(r.Context(), userID, claims)
+		}
+		authTime := claims.IssuedAt
+		if claims.AuthTime != nil {
+			authTime = claims.AuthTime
+		}
+		p := principal{UserID: userID}
+		if authTime != nil {
+			p.AuthTime = authTime.Time
+		}
+		return cfg.resolvePrincipal(r.Context(), p)
+	}
+
+	pat, err := cfg.db.GetPersonalAccessTokenByHash(r.Context(), auth.HashToken(token))
+	if err != nil {
+		return principal{}, err
+	}
+
+	scopes, err := auth.ParseScopes(pat.Scopes)
+	if err != nil {
+		return principal{}, err
+	}
+
+	err = cfg.db.TouchPersonalAccessToken(r.Context(), pat.ID)
+	if err != nil {
+		return principal{}, err
+	}
+
+	return cfg.resolvePrincipal(r.Context(), principal{
+		UserID:  pat.UserID,
+		Scopes:  scopes,
+		TokenID: uuid.NullUUID{UUID: pat.ID, Valid: true},
+	})
+}
+
+// delegatedPrincipal resolves an access token issued to an OAuth client,
+// which stops working as soon as the user revokes the app's grant and the
+// grant is deleted, and only keeps the scopes the grant still has.
+func (cfg *apiConfig) delegatedPrincipal(ctx context.Context, userID uuid.UUID, claims auth.AccessClaims) (principal, error) {
+	grantID, err := uuid.Parse(claims.GrantID)
+	if err != nil {
+		return principal{}, err
+	}
+
+	grant, err := cfg.db.GetOAuthGrant(ctx, grantID)
+	if err != nil {
+		return principal{}, err
+	}
+	if grant.UserID != userID {
+		return principal{}, errGrantMismatch
+	}
+
+	tokenScopes, err := auth.ParseScopes(strings.Fields(claims.Scope))
+	if err != nil {
+		return principal{}, err
+	}
+
+	// the user may have re-consented with fewer scopes since the token was
+	// issued; non-nil even when empty, since nil means unrestricted
+	scopes := []auth.Scope{}
+	for _, scope := range tokenScopes {
+		if slices.Contains(grant.Scopes, string(scope)) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return cfg.resolvePrincipal(ctx, principal{
+		UserID:  userID,
+		Scopes:  scopes,
+		GrantID: uuid.NullUUID{UUID: grant.ID, Valid: true},
+	})
+}
+
+// resolvePrincipal loads the caller's user row so the current role and
+// verification state are used rather than whatever was true when the token
+// was issued, and rejects suspended users.
+func (cfg *apiConfig) resolvePrincipal(ctx context.Context, p principal) (principal, error) {
+	user, err := cfg.db.GetUserByID(ctx, p.UserID)
+	if err != nil {
+		return principal{}, err
+	}
+	if user.DeletedAt.Valid {
+		return principal{}, errAccountDeleted
+	}
+	// checked on every request so suspending someone also cuts off the
+	// access tokens they already hold
+	_, suspended, err := cfg.activeRestriction(ctx, user.ID, restrictionSuspension)
+	if err != nil {
+		return principal{}, err
+	}
+	if suspended {
+		return principal{}, errAccountSuspended
+	}
+	p.Role = auth.Role(user.Role)
+	p.EmailVerified = user.EmailVerifiedAt.Valid
+	p.IsChirpyRed = user.IsChirpyRed
+	return p, nil
+}
+
+// requireScope authenticates the request and checks the caller was granted
+// scope, writing the error response itself when it returns false.
+func (cfg *apiConfig) requireScope(w http.ResponseWriter, r *http.Request, scope auth.Scope) (principal, bool) {
+	p, err := cfg.authenticate(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		return principal{}, false
+	}
+
+	if !p.hasScope(scope) {
+		respondWithError(w, http.StatusForbidden, "Token is missing scope "+string(scope), errMissingScope)
+		return principal{}, false
+	}
+
+	return p, true
+}
+
+// optionalScope enforces scope only when the request carries credentials,
+// for endpoints that are also open to anonymous callers, who get the zero
+// principal.
+func (cfg *apiConfig) optionalScope(w http.ResponseWriter, r *http.Request, scope auth.Scope) (principal, bool) {
+	if r.Header.Get("Authorization") == "" {
+		return principal{}, true
+	}
+	return cfg.requireScope(w, r, scope)
+}
+
+// middlewareRequireRole only lets login sessions whose user holds one of
+// roles through, and makes the caller available via principalFromContext.
+func (cfg *apiConfig) middlewareRequireRole(next http.Handler, roles ...auth.Role) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := cfg.authenticate(r)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+			return
+		}
+
+		if !p.isSession() {
+			respondWithError(w, http.StatusForbidden, "Access tokens for apps can't be used here", nil)
+			return
+		}
+
+		if !p.hasRole(roles...) {
+			respondWithError(w, http.StatusForbidden, "Not allowed", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalContextKey{}, p)))
+	})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/mail"
+	"github.com/iamjoona/chippy/internal/webhook"
+)
+
+// Event types integrators can subscribe to.
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+	eventUserUpgraded = "user.upgraded"
+)
+
+var outboundEventTypes = []string{eventChirpCreated, eventChirpDeleted, eventUserUpgraded}
+
+// outboundEvent is the JSON body of every delivery.
+type outboundEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// publishEvent queues a delivery of an event about userID to every endpoint
+// subscribed to it: the user's own endpoints and those admins registered.
+// Failing to queue is logged rather than surfaced so it can't fail the
+// request that caused the event.
+func (cfg *apiConfig) publishEvent(ctx context.Context, eventType string, userID uuid.UUID, data any) {
+	err := cfg.queueEvent(ctx, eventType, userID, data)
+	if err != nil {
+		log.Printf("Error queueing %s webhooks: %v", eventType, err)
+	}
+}
+
+func (cfg *apiConfig) queueEvent(ctx context.Context, eventType string, userID uuid.UUID, data any) error {
+	endpoints, err := cfg.db.ListWebhookEndpointsForEvent(ctx, database.ListWebhookEndpointsForEventParams{
+		EventType: eventType,
+		UserID:    userID,
+	})
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+
+	event := outboundEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		err := cfg.db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			ID:         uuid.New(),
+			EndpointID: endpoint.ID,
+			EventID:    event.ID,
+			EventType:  eventType,
+			Payload:    payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookDispatcher delivers queued events, retrying failures with
+// exponential backoff up to MaxAttempts times and disabling endpoints that
+// fail DisableAfter times in a row.
+type webhookDispatcher struct {
+	cfg          *apiConfig
+	Sender       webhook.Sender
+	Interval     time.Duration
+	BatchSize    int32
+	MaxAttempts  int32
+	DisableAfter int32
+}
+
+// Run dispatches pending deliveries every Interval until ctx is cancelled.
+func (d *webhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := d.DispatchPending(ctx)
+		if err != nil {
+			log.Printf("Error dispatching webhooks: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchPending attempts one batch of due deliveries and returns how many
+// were delivered.
+func (d *webhookDispatcher) DispatchPending(ctx context.Context) (int, error) {
+	pending, err := d.cfg.db.ListPendingWebhookDeliveries(ctx, database.ListPendingWebhookDeliveriesParams{
+		MaxAttempts: d.MaxAttempts,
+		BatchSize:   d.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	disabled := map[uuid.UUID]bool{}
+	for _, delivery := range pending {
+		// an endpoint disabled earlier in this batch waits until it is
+		// enabled again
+		if disabled[delivery.EndpointID] {
+			continue
+		}
+
+		result := d.Sender.Send(ctx, webhook.Delivery{
+			ID:        delivery.ID.String(),
+			URL:       delivery.Url,
+			Secret:    delivery.Secret,
+			EventType: delivery.EventType,
+			Body:      delivery.Payload,
+		}, time.Now())
+
+		err := d.recordAttempt(ctx, delivery.ID, result)
+		if err != nil {
+			return delivered, err
+		}
+
+		if result.Err == nil {
+			err = d.cfg.db.MarkWebhookDeliveryDelivered(ctx, delivery.ID)
+			if err != nil {
+				return delivered, err
+			}
+			err = d.cfg.db.RecordWebhookEndpointSuccess(ctx, delivery.EndpointID)
+			if err != nil {
+				return delivered, err
+			}
+			delivered++
+			continue
+		}
+
+		err = d.cfg.db.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+			ID:            delivery.ID,
+			LastError:     sql.NullString{String: result.Err.Error(), Valid: true},
+			NextAttemptAt: time.Now().UTC().Add(webhook.RetryDelay(int(delivery.Attempts) + 1)),
+		})
+		if err != nil {
+			return delivered, err
+		}
+
+		endpoint, err := d.cfg.db.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{
+			ID:           delivery.EndpointID,
+			DisableAfter: d.DisableAfter,
+		})
+		if err != nil {
+			return delivered, err
+		}
+		if endpoint.DisabledAt.Valid {
+			disabled[endpoint.ID] = true
+			if endpoint.ConsecutiveFailures == d.DisableAfter {
+				d.notifyDisabled(ctx, endpoint)
+			}
+		}
+	}
+
+	return delivered, nil
+}
+
+func (d *webhookDispatcher) recordAttempt(ctx context.Context, deliveryID uuid.UUID, result webhook.Result) error {
+	statusCode := sql.NullInt32{}
+	if result.StatusCode != 0 {
+		statusCode = sql.NullInt32{Int32: int32(result.StatusCode), Valid: true}
+	}
+	errText := sql.NullString{}
+	if result.Err != nil {
+		errText = sql.NullString{String: result.Err.Error(), Valid: true}
+	}
+	return d.cfg.db.CreateWebhookDeliveryAttempt(ctx, database.CreateWebhookDeliveryAttemptParams{
+		ID:         uuid.New(),
+		DeliveryID: deliveryID,
+		StatusCode: statusCode,
+		Error:      errText,
+		DurationMs: int32(result.Duration.Milliseconds()),
+	})
+}
+
+// notifyDisabled tells an endpoint's owner it has been switched off.
+func (d *webhookDispatcher) notifyDisabled(ctx context.Context, endpoint database.WebhookEndpoint) {
+	log.Printf("Disabled webhook endpoint %s after %d consecutive failures", endpoint.ID, endpoint.ConsecutiveFailures)
+	if !endpoint.OwnerID.Valid {
+		return
+	}
+
+	owner, err := d.cfg.db.GetUserByID(ctx, endpoint.OwnerID.UUID)
+	if err != nil {
+		log.Printf("Error loading owner of webhook endpoint %s: %v", endpoint.ID, err)
+		return
+	}
+
+	err = d.cfg.mailer.Send(ctx, mail.Message{
+		To:      owner.Email,
+		Subject: "Your Chirpy webhook endpoint was disabled",
+		Body: fmt.Sprintf(
+			"Deliveries to %s failed %d times in a row, so we stopped sending them.\n\n"+
+				"Undelivered events are kept. Fix the endpoint and enable it again to receive them.\n",
+			endpoint.Url, endpoint.ConsecutiveFailures,
+		),
+	})
+	if err != nil {
+		log.Printf("Error queueing webhook disabled email: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/database"
+)
+
+// Kinds of user restriction. A suspended user can't log in or use the
+// tokens they hold; a shadow-banned user carries on as normal but their
+// chirps are only shown to them.
+const (
+	restrictionSuspension = "suspension"
+	restrictionShadowBan  = "shadow_ban"
+)
+
+var restrictionKinds = []string{restrictionSuspension, restrictionShadowBan}
+
+var errAccountSuspended = errors.New("account is suspended")
+
+// activeRestriction returns the restriction of kind currently in force for
+// userID, if any.
+func (cfg *apiConfig) activeRestriction(ctx context.Context, userID uuid.UUID, kind string) (database.UserRestriction, bool, error) {
+	restriction, err := cfg.db.GetActiveUserRestriction(ctx, database.GetActiveUserRestrictionParams{
+		UserID: userID,
+		Kind:   kind,
+	})
+	if err == sql.ErrNoRows {
+		return database.UserRestriction{}, false, nil
+	}
+	if err != nil {
+		return database.UserRestriction{}, false, err
+	}
+	return restriction, true, nil
+}
+
+// rejectSuspended responds 403 and returns true when userID is suspended.
+func (cfg *apiConfig) rejectSuspended(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	suspension, suspended, err := cfg.activeRestriction(r.Context(), userID, restrictionSuspension)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check account status", err)
+		return true
+	}
+	if !suspended {
+		return false
+	}
+
+	msg := "Your account is suspended: " + suspension.Reason
+	if suspension.ExpiresAt.Valid {
+		msg = fmt.Sprintf("Your account is suspended until %s: %s", suspension.ExpiresAt.Time.Format(time.RFC1123), suspension.Reason)
+	}
+	respondWithError(w, http.StatusForbidden, msg, errAccountSuspended)
+	return true
+}
+
+// chirpVisibleTo reports whether viewer may see chirp. Moderators' hidden
+// chirps are shown to no one, and a shadow-banned author's only to them.
+// The rule lives in the chirp_visible_to SQL function, which the chirp
+// listing queries use too.
+func (cfg *apiConfig) chirpVisibleTo(ctx context.Context, chirp database.Chirp, viewer uuid.NullUUID) (bool, error) {
+	visible, err := cfg.db.ChirpVisibleTo(ctx, database.ChirpVisibleToParams{
+		ViewerID: viewer,
+		ID:       chirp.ID,
+	})
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return visible, err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/auth"
+	"github.com/iamjoona/chippy/internal/database"
+)
+
+const (
+	accessTokenTTL  = time.Hour
+	refreshTokenTTL = time.Hour * 24 * 60
+)
+
+// issueSession starts a new login session, returning an access JWT and the
+// refresh token that can renew it.
+func (cfg *apiConfig) issueSession(ctx context.Context, userID uuid.UUID) (string, string, error) {
+	accessToken, err := auth.MakeJWT(
+		userID,
+		cfg.jwtSecret,
+		accessTokenTTL,
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = cfg.db.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		UserID:    userID,
+		Token:     refreshToken,
+		ExpiresAt: time.Now().UTC().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
@@ -0,0 +1,60 @@
+-- +goose Up
+-- Refuse to migrate while two accounts differ only by case or surrounding
+-- whitespace; the error lists them so they can be merged or renamed first.
+-- +goose StatementBegin
+DO $$
+DECLARE
+    collisions TEXT;
+BEGIN
+    SELECT string_agg(dupes.accounts, '; ')
+    INTO collisions
+    FROM (
+        SELECT lower(btrim(email)) || ' => ' || string_agg(email || ' (' || id || ')', ', ' ORDER BY created_at) AS accounts
+        FROM users
+        GROUP BY lower(btrim(email))
+        HAVING COUNT(*) > 1
+    ) dupes;
+
+    IF collisions IS NOT NULL THEN
+        RAISE EXCEPTION 'users with case-insensitively duplicate emails: %', collisions;
+    END IF;
+END $$;
+-- +goose StatementEnd
+
+-- Accounts auth.NormalizeEmail would rewrite (internationalised domains) or
+-- reject (no dot in the domain, parts too long) can't be looked up by the
+-- address their owners type any more. SQL can only approximate that check,
+-- so this just warns about the likely ones; `go run ./cmd/backfill-emails`
+-- applies the real normalisation and reports what it can't fix.
+-- +goose StatementBegin
+DO $$
+DECLARE
+    affected TEXT;
+BEGIN
+    SELECT string_agg(email || ' (' || id || ')', ', ' ORDER BY created_at)
+    INTO affected
+    FROM users
+    WHERE octet_length(email) <> length(email)
+    OR btrim(email) !~ '^[^@[:space:][:cntrl:]]{1,64}@[^@]+\.[^@]+$'
+    OR length(btrim(email)) > 254;
+
+    IF affected IS NOT NULL THEN
+        RAISE WARNING 'users whose emails need cmd/backfill-emails: %', affected;
+    END IF;
+END $$;
+-- +goose StatementEnd
+
+UPDATE users
+SET email = lower(btrim(email))
+WHERE email <> lower(btrim(email));
+
+ALTER TABLE users
+DROP CONSTRAINT users_email_key;
+
+CREATE UNIQUE INDEX users_email_lower_idx ON users (lower(email));
+
+-- +goose Down
+DROP INDEX users_email_lower_idx;
+
+ALTER TABLE users
+ADD CONSTRAINT users_email_key UNIQUE (email);
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE invite_codes (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    code TEXT NOT NULL UNIQUE,
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    max_uses INTEGER NOT NULL CHECK (max_uses > 0),
+    uses INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX invite_codes_created_by_idx ON invite_codes (created_by);
+
+ALTER TABLE users
+ADD COLUMN invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+ADD COLUMN invite_code_id UUID REFERENCES invite_codes(id) ON DELETE SET NULL;
+
+CREATE INDEX users_invited_by_idx ON users (invited_by);
+
+-- +goose Down
+DROP INDEX users_invited_by_idx;
+
+ALTER TABLE users
+DROP COLUMN invite_code_id,
+DROP COLUMN invited_by;
+
+DROP TABLE invite_codes;
@@ -0,0 +1,67 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN hidden_at TIMESTAMP;
+
+CREATE TABLE chirp_reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    -- NULL once the chirp is deleted; the body is kept below as evidence
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    chirp_body TEXT NOT NULL,
+    author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- spam, harassment, hate, violence, self_harm, misinformation or other
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    assignee_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    -- the action that resolved the report; NULL while it is open
+    resolution TEXT,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    UNIQUE (chirp_id, reporter_id)
+);
+
+CREATE INDEX chirp_reports_open_idx ON chirp_reports (created_at)
+WHERE resolved_at IS NULL;
+
+CREATE TABLE user_restrictions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- suspension
+    kind TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    -- NULL for restrictions that last until lifted
+    expires_at TIMESTAMP,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    lifted_at TIMESTAMP
+);
+
+CREATE INDEX user_restrictions_user_id_idx ON user_restrictions (user_id)
+WHERE lifted_at IS NULL;
+
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    -- assign, dismiss, hide_chirp, delete_chirp, warn_author or
+    -- suspend_author
+    action TEXT NOT NULL,
+    report_id UUID REFERENCES chirp_reports(id) ON DELETE SET NULL,
+    -- not a foreign key so the trail outlives deleted chirps
+    chirp_id UUID,
+    target_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX moderation_actions_report_id_idx ON moderation_actions (report_id);
+CREATE INDEX moderation_actions_target_user_id_idx ON moderation_actions (target_user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE user_restrictions;
+DROP TABLE chirp_reports;
+
+ALTER TABLE chirps
+DROP COLUMN hidden_at;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE spam_holds (
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    -- the rules that scored the chirp
+    rules TEXT[] NOT NULL
+);
+
+CREATE INDEX chirps_user_id_created_at_idx ON chirps (user_id, created_at DESC);
+
+-- +goose Down
+DROP INDEX chirps_user_id_created_at_idx;
+DROP TABLE spam_holds;
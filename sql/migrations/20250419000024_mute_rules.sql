@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE mute_rules (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- a rule mutes either a keyword or an author
+    keyword TEXT,
+    whole_word BOOLEAN NOT NULL DEFAULT FALSE,
+    regex BOOLEAN NOT NULL DEFAULT FALSE,
+    muted_user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP,
+    CHECK ((keyword IS NULL) <> (muted_user_id IS NULL))
+);
+
+CREATE INDEX mute_rules_user_id_idx ON mute_rules (user_id);
+
+-- +goose Down
+DROP TABLE mute_rules;
@@ -0,0 +1,36 @@
+-- name: CreateChirpReport :one
+INSERT INTO chirp_reports (id, created_at, updated_at, chirp_id, chirp_body, author_id, reporter_id, reason, details)
+VALUES ($1, NOW(), NOW(), $2, $3, $4, $5, $6, $7)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: GetChirpReport :one
+SELECT * FROM chirp_reports
+WHERE id = $1;
+
+-- name: ListChirpReports :many
+SELECT * FROM chirp_reports
+WHERE (sqlc.arg(status)::text = ''
+    OR (sqlc.arg(status)::text = 'open' AND resolved_at IS NULL)
+    OR (sqlc.arg(status)::text = 'resolved' AND resolved_at IS NOT NULL))
+AND (sqlc.arg(reason)::text = '' OR reason = sqlc.arg(reason)::text)
+AND (NOT sqlc.arg(filter_assignee)::boolean OR assignee_id IS NOT DISTINCT FROM sqlc.narg(assignee_id)::uuid)
+ORDER BY created_at ASC
+LIMIT sqlc.arg(max_results)::integer;
+
+-- name: AssignChirpReport :one
+UPDATE chirp_reports
+SET assignee_id = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ResolveChirpReports :many
+UPDATE chirp_reports
+SET resolution = sqlc.arg(resolution)::text,
+resolved_by = sqlc.arg(resolved_by)::uuid,
+resolved_at = NOW(),
+updated_at = NOW()
+WHERE resolved_at IS NULL
+AND (id = sqlc.arg(id) OR chirp_id = sqlc.narg(chirp_id)::uuid)
+RETURNING *;
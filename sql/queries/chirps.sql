@@ -5,11 +5,27 @@ RETURNING *;
 
 -- name: GetAllChirps :many
 SELECT * FROM chirps
+WHERE TRUE
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.deleted_at IS NOT NULL
+)
+AND chirp_visible_to(chirps.user_id, chirps.hidden_at, sqlc.narg(viewer_id)::uuid)
 ORDER BY created_at ASC;
 
 -- name: GetChirpByID :one
 SELECT * FROM chirps
-WHERE id = $1;
+WHERE id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.deleted_at IS NOT NULL
+);
+
+-- name: ChirpVisibleTo :one
+SELECT chirp_visible_to(user_id, hidden_at, sqlc.narg(viewer_id)::uuid)::boolean AS visible FROM chirps
+WHERE id = sqlc.arg(id);
 
 -- name: DeleteChirpByID :exec
 DELETE FROM chirps
@@ -18,14 +34,54 @@ RETURNING *;
 
 -- name: GetChirpsByUserID :many
 SELECT * FROM chirps
-WHERE user_id = $1
+WHERE user_id = sqlc.arg(user_id)
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.deleted_at IS NOT NULL
+)
+AND chirp_visible_to(chirps.user_id, chirps.hidden_at, sqlc.narg(viewer_id)::uuid)
 ORDER BY created_at ASC;
 
 -- name: GetAllChirpsDesc :many
 SELECT * FROM chirps
+WHERE TRUE
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.deleted_at IS NOT NULL
+)
+AND chirp_visible_to(chirps.user_id, chirps.hidden_at, sqlc.narg(viewer_id)::uuid)
 ORDER BY created_at DESC;
 
 -- name: GetChirpsByUserIDDesc :many
 SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id
+    AND users.deleted_at IS NOT NULL
+)
+AND chirp_visible_to(chirps.user_id, chirps.hidden_at, sqlc.narg(viewer_id)::uuid)
+ORDER BY created_at DESC;
+
+-- name: ListAllChirpsByUserID :many
+SELECT * FROM chirps
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1;
+
+-- name: UnhideChirp :exec
+UPDATE chirps
+SET hidden_at = NULL
+WHERE id = $1;
+
+-- name: ListRecentChirpsByUserID :many
+SELECT * FROM chirps
 WHERE user_id = $1
-ORDER BY created_at DESC;
\ No newline at end of file
+AND created_at > $2
+ORDER BY created_at DESC;
@@ -0,0 +1,68 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, updated_at, user_id)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2
+)
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports
+WHERE id = $1
+AND user_id = $2;
+
+-- name: GetDataExportByID :one
+SELECT * FROM data_exports
+WHERE id = $1;
+
+-- name: GetUnfinishedDataExportForUser :one
+SELECT * FROM data_exports
+WHERE user_id = $1
+AND status IN ('pending', 'running')
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: ClaimPendingDataExport :one
+UPDATE data_exports
+SET status = 'running',
+updated_at = NOW()
+WHERE id = (
+    SELECT id FROM data_exports
+    WHERE status = 'pending'
+    OR (status = 'running' AND updated_at < sqlc.arg(stale_before)::timestamp)
+    ORDER BY created_at ASC
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'completed',
+blob_key = $2,
+completed_at = NOW(),
+expires_at = $3,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed',
+error = $2,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteExpiredDataExports :many
+DELETE FROM data_exports
+WHERE expires_at IS NOT NULL
+AND expires_at < NOW()
+RETURNING *;
+
+-- name: ListDataExportBlobsForPurge :many
+SELECT data_exports.blob_key FROM data_exports
+JOIN users ON users.id = data_exports.user_id
+WHERE users.deleted_at IS NOT NULL
+AND users.deleted_at < sqlc.arg(deleted_before)::timestamp
+AND data_exports.blob_key IS NOT NULL;
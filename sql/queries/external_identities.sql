@@ -0,0 +1,59 @@
+-- name: CreateExternalIdentity :one
+INSERT INTO external_identities (id, created_at, updated_at, user_id, provider, subject, email, last_login_at)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW()
+)
+RETURNING *;
+
+-- name: GetExternalIdentity :one
+SELECT * FROM external_identities
+WHERE provider = $1
+AND subject = $2;
+
+-- name: TouchExternalIdentity :exec
+UPDATE external_identities
+SET email = $2,
+last_login_at = NOW(),
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListExternalIdentitiesByUserID :many
+SELECT * FROM external_identities
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteExternalIdentity :one
+DELETE FROM external_identities
+WHERE id = $1
+AND user_id = $2
+RETURNING *;
+
+-- name: CreateOIDCLoginState :exec
+INSERT INTO oidc_login_states (state_hash, created_at, provider, code_verifier, nonce, link_user_id, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+);
+
+-- name: ConsumeOIDCLoginState :one
+DELETE FROM oidc_login_states
+WHERE state_hash = $1
+AND provider = $2
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredOIDCLoginStates :exec
+DELETE FROM oidc_login_states
+WHERE expires_at <= NOW();
@@ -0,0 +1,50 @@
+-- name: CreateInviteCode :one
+INSERT INTO invite_codes (id, created_at, updated_at, code, created_by, max_uses, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: ListInviteCodesByCreator :many
+SELECT * FROM invite_codes
+WHERE created_by = $1
+ORDER BY created_at DESC;
+
+-- name: ListInviteCodes :many
+SELECT * FROM invite_codes
+ORDER BY created_at DESC;
+
+-- name: CountActiveInviteCodesByCreator :one
+SELECT COUNT(*) FROM invite_codes
+WHERE created_by = $1
+AND revoked_at IS NULL
+AND uses < max_uses
+AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: RedeemInviteCode :one
+UPDATE invite_codes
+SET uses = uses + 1,
+updated_at = NOW()
+WHERE code = $1
+AND revoked_at IS NULL
+AND uses < max_uses
+AND (expires_at IS NULL OR expires_at > NOW())
+RETURNING *;
+
+-- name: RevokeInviteCode :one
+UPDATE invite_codes
+SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE id = $1
+AND revoked_at IS NULL
+RETURNING *;
+
+-- name: GetInviteCodeByID :one
+SELECT * FROM invite_codes
+WHERE id = $1;
@@ -0,0 +1,31 @@
+-- name: CreateLoginAttempt :exec
+INSERT INTO login_attempts (id, created_at, email, ip_address, user_id, succeeded)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: GetLoginFailuresByEmail :one
+SELECT COUNT(*) AS failures, COALESCE(MAX(created_at), 'epoch')::timestamp AS last_failed_at FROM login_attempts
+WHERE email = $1
+AND succeeded = false
+AND created_at > sqlc.arg(since)
+AND created_at > COALESCE((
+    SELECT MAX(successes.created_at) FROM login_attempts AS successes
+    WHERE successes.email = login_attempts.email
+    AND successes.succeeded = true
+), 'epoch');
+
+-- name: GetLoginFailuresByIP :one
+SELECT COUNT(*) AS failures, COALESCE(MAX(created_at), 'epoch')::timestamp AS last_failed_at FROM login_attempts
+WHERE ip_address = $1
+AND succeeded = false
+AND created_at > sqlc.arg(since);
+
+-- name: DeleteLoginAttemptsByEmail :exec
+DELETE FROM login_attempts
+WHERE email = $1;
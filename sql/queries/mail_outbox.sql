@@ -0,0 +1,33 @@
+-- name: CreateOutboxMessage :one
+INSERT INTO mail_outbox (id, created_at, recipient, subject, body, next_attempt_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    NOW()
+)
+RETURNING *;
+
+-- name: ListPendingOutboxMessages :many
+SELECT * FROM mail_outbox
+WHERE sent_at IS NULL
+AND next_attempt_at <= NOW()
+AND attempts < sqlc.arg(max_attempts)
+ORDER BY created_at ASC
+LIMIT sqlc.arg(batch_size);
+
+-- name: MarkOutboxMessageSent :exec
+UPDATE mail_outbox
+SET sent_at = NOW(),
+attempts = attempts + 1,
+last_error = NULL
+WHERE id = $1;
+
+-- name: MarkOutboxMessageFailed :exec
+UPDATE mail_outbox
+SET attempts = attempts + 1,
+last_error = $2,
+next_attempt_at = $3
+WHERE id = $1;
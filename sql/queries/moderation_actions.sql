@@ -0,0 +1,15 @@
+-- name: CreateModerationAction :exec
+INSERT INTO moderation_actions (id, created_at, moderator_id, action, report_id, chirp_id, target_user_id, note)
+VALUES ($1, NOW(), $2, $3, $4, $5, $6, $7);
+
+-- name: ListModerationActionsByReport :many
+SELECT * FROM moderation_actions
+WHERE report_id = $1
+ORDER BY created_at ASC;
+
+-- name: ListModerationActions :many
+SELECT * FROM moderation_actions
+WHERE (NOT sqlc.arg(filter_target)::boolean OR target_user_id = sqlc.arg(target_user_id)::uuid)
+AND (NOT sqlc.arg(filter_moderator)::boolean OR moderator_id = sqlc.arg(moderator_id)::uuid)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results)::integer;
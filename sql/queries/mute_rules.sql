@@ -0,0 +1,23 @@
+-- name: CreateMuteRule :one
+INSERT INTO mute_rules (id, created_at, user_id, keyword, whole_word, regex, muted_user_id, expires_at)
+VALUES ($1, NOW(), $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetMuteRule :one
+SELECT * FROM mute_rules
+WHERE id = $1;
+
+-- name: ListActiveMuteRules :many
+SELECT * FROM mute_rules
+WHERE user_id = $1
+AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at ASC;
+
+-- name: DeleteMuteRule :exec
+DELETE FROM mute_rules
+WHERE id = $1;
+
+-- name: DeleteExpiredMuteRules :exec
+DELETE FROM mute_rules
+WHERE user_id = $1
+AND expires_at <= NOW();
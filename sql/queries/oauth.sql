@@ -0,0 +1,108 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, owner_id, name, secret_hash, redirect_uris, scopes)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: ListOAuthClientsByOwner :many
+SELECT * FROM oauth_clients
+WHERE owner_id = $1
+ORDER BY created_at DESC;
+
+-- name: DeleteOAuthClient :one
+DELETE FROM oauth_clients
+WHERE id = $1
+AND owner_id = $2
+RETURNING *;
+
+-- name: UpsertOAuthGrant :one
+INSERT INTO oauth_grants (id, created_at, updated_at, user_id, client_id, scopes)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id, client_id) DO UPDATE
+SET scopes = EXCLUDED.scopes,
+updated_at = NOW()
+RETURNING *;
+
+-- name: GetOAuthGrant :one
+SELECT * FROM oauth_grants
+WHERE id = $1;
+
+-- name: TouchOAuthGrant :exec
+UPDATE oauth_grants
+SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: ListOAuthGrantsByUser :many
+SELECT oauth_grants.*, oauth_clients.name AS client_name FROM oauth_grants
+JOIN oauth_clients ON oauth_clients.id = oauth_grants.client_id
+WHERE oauth_grants.user_id = $1
+ORDER BY oauth_grants.created_at DESC;
+
+-- name: DeleteOAuthGrant :one
+DELETE FROM oauth_grants
+WHERE client_id = $1
+AND user_id = $2
+RETURNING *;
+
+-- name: DeleteOAuthGrantByID :exec
+DELETE FROM oauth_grants
+WHERE id = $1;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, created_at, grant_id, redirect_uri, code_challenge, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: ConsumeOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE code_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: CreateOAuthRefreshToken :exec
+INSERT INTO oauth_refresh_tokens (token_hash, created_at, grant_id, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3
+);
+
+-- name: RevokeOAuthRefreshToken :one
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW()
+WHERE token_hash = $1
+AND revoked_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: GetOAuthRefreshToken :one
+SELECT * FROM oauth_refresh_tokens
+WHERE token_hash = $1;
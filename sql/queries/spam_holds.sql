@@ -0,0 +1,17 @@
+-- name: CreateSpamHold :exec
+INSERT INTO spam_holds (chirp_id, created_at, score, rules)
+VALUES ($1, NOW(), $2, $3);
+
+-- name: GetSpamHold :one
+SELECT * FROM spam_holds
+WHERE chirp_id = $1;
+
+-- name: ListSpamHolds :many
+SELECT spam_holds.*, chirps.body, chirps.user_id FROM spam_holds
+JOIN chirps ON chirps.id = spam_holds.chirp_id
+ORDER BY spam_holds.created_at ASC
+LIMIT sqlc.arg(max_results)::integer;
+
+-- name: DeleteSpamHold :exec
+DELETE FROM spam_holds
+WHERE chirp_id = $1;
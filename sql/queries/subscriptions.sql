@@ -0,0 +1,32 @@
+-- name: GetSubscriptionByUserID :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: LockSubscriptionByUserID :one
+SELECT * FROM subscriptions
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end, canceled_at)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id) DO UPDATE
+SET status = EXCLUDED.status,
+current_period_end = EXCLUDED.current_period_end,
+canceled_at = EXCLUDED.canceled_at,
+updated_at = NOW()
+RETURNING *;
+
+-- name: ListLapsedSubscriptions :many
+SELECT * FROM subscriptions
+WHERE status <> 'expired'
+AND current_period_end <= sqlc.arg(now)::timestamp
+ORDER BY current_period_end ASC
+LIMIT sqlc.arg(max_results)::integer;
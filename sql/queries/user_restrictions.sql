@@ -0,0 +1,37 @@
+-- name: CreateUserRestriction :one
+INSERT INTO user_restrictions (id, created_at, user_id, kind, reason, expires_at, created_by)
+VALUES ($1, NOW(), $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetActiveUserRestriction :one
+SELECT * FROM user_restrictions
+WHERE user_id = $1
+AND kind = $2
+AND lifted_at IS NULL
+AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY expires_at DESC NULLS FIRST
+LIMIT 1;
+
+-- name: GetUserRestriction :one
+SELECT * FROM user_restrictions
+WHERE id = $1;
+
+-- name: ListUserRestrictions :many
+SELECT * FROM user_restrictions
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListActiveUserRestrictions :many
+SELECT * FROM user_restrictions
+WHERE (sqlc.arg(kind)::text = '' OR kind = sqlc.arg(kind)::text)
+AND lifted_at IS NULL
+AND (expires_at IS NULL OR expires_at > NOW())
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results)::integer;
+
+-- name: LiftUserRestriction :one
+UPDATE user_restrictions
+SET lifted_at = NOW()
+WHERE id = $1
+AND lifted_at IS NULL
+RETURNING *;
@@ -9,7 +9,17 @@ RETURNING *;
 
 -- name: GetUserByEmail :one
 SELECT * FROM users
-WHERE email = $1;
+WHERE lower(email) = lower(sqlc.arg(email)::text);
+
+-- name: ListUserEmails :many
+SELECT id, email FROM users
+ORDER BY created_at ASC;
+
+-- name: SetUserEmail :exec
+UPDATE users
+SET email = $2,
+updated_at = NOW()
+WHERE id = $1;
 
 -- name: UpdateUser :one
 UPDATE users 
@@ -20,8 +30,90 @@ SET
 WHERE id = $1
 RETURNING *;
 
--- name: UpgradeUserToChirpyRed :one
+-- name: SetUserChirpyRed :one
+UPDATE users
+SET is_chirpy_red = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CountUsersByRole :one
+SELECT COUNT(*) FROM users
+WHERE role = $1;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2,
+updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserPendingEmail :one
+UPDATE users
+SET pending_email = $2,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkUserEmailVerified :one
+UPDATE users
+SET email = $2,
+email_verified_at = NOW(),
+pending_email = NULL,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE lower(handle) = lower(sqlc.arg(handle)::text);
+
+-- name: UpdateUserProfile :one
 UPDATE users
-SET is_chirpy_red = true
+SET handle = $2,
+display_name = $3,
+bio = $4,
+avatar_url = $5,
+updated_at = NOW()
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: SoftDeleteUser :one
+UPDATE users
+SET deleted_at = NOW(),
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deleted_at IS NOT NULL
+AND deleted_at < sqlc.arg(deleted_before)::timestamp
+RETURNING *;
+
+-- name: SetUserInvite :exec
+UPDATE users
+SET invited_by = $2,
+invite_code_id = $3
+WHERE id = $1;
+
+-- name: ListUsersInvitedBy :many
+SELECT * FROM users
+WHERE invited_by = $1
+ORDER BY created_at ASC;
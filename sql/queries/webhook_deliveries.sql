@@ -0,0 +1,61 @@
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, created_at, endpoint_id, event_id, event_type, payload, next_attempt_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW()
+);
+
+-- name: ListPendingWebhookDeliveries :many
+SELECT webhook_deliveries.*, webhook_endpoints.url, webhook_endpoints.secret FROM webhook_deliveries
+JOIN webhook_endpoints ON webhook_endpoints.id = webhook_deliveries.endpoint_id
+WHERE webhook_deliveries.delivered_at IS NULL
+AND webhook_deliveries.next_attempt_at <= NOW()
+AND webhook_deliveries.attempts < sqlc.arg(max_attempts)
+AND webhook_endpoints.disabled_at IS NULL
+ORDER BY webhook_deliveries.created_at ASC
+LIMIT sqlc.arg(batch_size);
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1;
+
+-- name: ListWebhookDeliveriesByEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results)::integer;
+
+-- name: MarkWebhookDeliveryDelivered :exec
+UPDATE webhook_deliveries
+SET delivered_at = NOW(),
+attempts = attempts + 1,
+last_error = NULL
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+last_error = $2,
+next_attempt_at = $3
+WHERE id = $1;
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3,
+    $4,
+    $5
+);
+
+-- name: ListWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at ASC;
@@ -0,0 +1,59 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, owner_id, url, secret, event_types)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: ListWebhookEndpointsByOwner :many
+SELECT * FROM webhook_endpoints
+WHERE owner_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListGlobalWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE owner_id IS NULL
+ORDER BY created_at DESC;
+
+-- name: ListWebhookEndpointsForEvent :many
+SELECT * FROM webhook_endpoints
+WHERE disabled_at IS NULL
+AND sqlc.arg(event_type)::text = ANY(event_types)
+AND (owner_id IS NULL OR owner_id = sqlc.arg(user_id)::uuid);
+
+-- name: DeleteWebhookEndpoint :exec
+DELETE FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: EnableWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET disabled_at = NULL,
+consecutive_failures = 0,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: RecordWebhookEndpointSuccess :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0
+WHERE id = $1;
+
+-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+disabled_at = CASE
+    WHEN disabled_at IS NULL AND consecutive_failures + 1 >= sqlc.arg(disable_after)::integer THEN NOW()
+    ELSE disabled_at
+END
+WHERE id = sqlc.arg(id)
+RETURNING *;
@@ -0,0 +1,51 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, provider, event_id, event_type, payload, received_at, outcome)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW(),
+    'received'
+)
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: GetWebhookEventByEventID :one
+SELECT * FROM webhook_events
+WHERE provider = $1
+AND event_id = $2;
+
+-- name: ClaimWebhookEvent :one
+UPDATE webhook_events
+SET outcome = 'processing',
+attempts = attempts + 1,
+claimed_at = NOW()
+WHERE id = sqlc.arg(id)
+AND (
+    outcome IN ('received', 'failed')
+    OR (outcome = 'processing' AND claimed_at < sqlc.arg(stale_before)::timestamp)
+    OR (outcome <> 'processing' AND sqlc.arg(replay)::boolean)
+)
+RETURNING *;
+
+-- name: FinishWebhookEvent :one
+UPDATE webhook_events
+SET outcome = $2,
+error = $3,
+processed_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.arg(provider)::text = '' OR provider = sqlc.arg(provider)::text)
+AND (sqlc.arg(event_type)::text = '' OR event_type = sqlc.arg(event_type)::text)
+AND (sqlc.arg(outcome)::text = '' OR outcome = sqlc.arg(outcome)::text)
+ORDER BY received_at DESC
+LIMIT sqlc.arg(max_results)::integer;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE login_attempts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    email TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    succeeded BOOLEAN NOT NULL
+);
+
+CREATE INDEX login_attempts_email_created_at_idx ON login_attempts (email, created_at);
+CREATE INDEX login_attempts_ip_address_created_at_idx ON login_attempts (ip_address, created_at);
+
+-- +goose Down
+DROP TABLE login_attempts;
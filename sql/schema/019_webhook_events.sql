@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY,
+    provider TEXT NOT NULL,
+    -- the sender's ID for the event, used to drop redelivered events
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    received_at TIMESTAMP NOT NULL,
+    -- received, processing, processed, ignored or failed
+    outcome TEXT NOT NULL,
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    processed_at TIMESTAMP,
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX webhook_events_received_at_idx ON webhook_events (received_at DESC);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    -- trialing, active, past_due, canceled or expired
+    status TEXT NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    canceled_at TIMESTAMP
+);
+
+CREATE INDEX subscriptions_current_period_end_idx ON subscriptions (current_period_end)
+WHERE status <> 'expired';
+
+-- existing members never had a period; give them one so a renewal can
+-- take over from here
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end)
+SELECT id, NOW(), NOW(), 'active', NOW() + INTERVAL '30 days'
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
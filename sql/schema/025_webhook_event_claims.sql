@@ -0,0 +1,13 @@
+-- +goose Up
+-- when processing last started, so an event left processing by a crash can
+-- be picked up again
+ALTER TABLE webhook_events
+ADD COLUMN claimed_at TIMESTAMP;
+
+UPDATE webhook_events
+SET claimed_at = received_at
+WHERE outcome = 'processing';
+
+-- +goose Down
+ALTER TABLE webhook_events
+DROP COLUMN claimed_at;
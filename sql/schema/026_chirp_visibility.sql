@@ -0,0 +1,24 @@
+-- +goose Up
+-- The rule for who may see a chirp: hidden chirps are shown to no one, and
+-- a shadow-banned author's only to them. The chirp listings and
+-- chirpVisibleTo both call this so they can't disagree.
+-- +goose StatementBegin
+CREATE FUNCTION chirp_visible_to(author_id UUID, hidden_at TIMESTAMP, viewer_id UUID)
+RETURNS BOOLEAN
+LANGUAGE sql
+STABLE
+AS $$
+    SELECT hidden_at IS NULL AND (
+        COALESCE(author_id = viewer_id, FALSE) OR NOT EXISTS (
+            SELECT 1 FROM user_restrictions
+            WHERE user_restrictions.user_id = author_id
+            AND user_restrictions.kind = 'shadow_ban'
+            AND user_restrictions.lifted_at IS NULL
+            AND (user_restrictions.expires_at IS NULL OR user_restrictions.expires_at > NOW())
+        )
+    )
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION chirp_visible_to(UUID, TIMESTAMP, UUID);
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamjoona/chippy/internal/database"
+	"github.com/iamjoona/chippy/internal/subscription"
+)
+
+const subscriptionExpiryBatchSize = 500
+
+func subscriptionStateFromDB(sub database.Subscription) subscription.State {
+	state := subscription.State{
+		Status:           subscription.Status(sub.Status),
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+	}
+	if sub.CanceledAt.Valid {
+		state.CanceledAt = sub.CanceledAt.Time
+	}
+	return state
+}
+
+// changeSubscription applies change to a user's subscription. It returns
+// sql.ErrNoRows if the user doesn't exist and an error wrapping
+// subscription.ErrInvalidTransition if the change makes no sense from the
+// current state.
+func (cfg *apiConfig) changeSubscription(ctx context.Context, userID uuid.UUID, change subscription.Change) error {
+	return cfg.updateSubscription(ctx, userID, func(state subscription.State, now time.Time) (subscription.State, bool, error) {
+		next, err := state.Apply(change, now)
+		return next, err == nil, err
+	})
+}
+
+// updateSubscription locks a user's subscription, passes it to update and
+// saves the result if update reports a change.
+func (cfg *apiConfig) updateSubscription(
+	ctx context.Context,
+	userID uuid.UUID,
+	update func(state subscription.State, now time.Time) (subscription.State, bool, error),
+) error {
+	now := time.Now().UTC()
+
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	current, err := qtx.LockSubscriptionByUserID(ctx, userID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	next, changed, err := update(subscriptionStateFromDB(current), now)
+	if err != nil || !changed {
+		return err
+	}
+
+	// is_chirpy_red is only ever written here, from the subscription state
+	_, err = qtx.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{
+		ID:          userID,
+		IsChirpyRed: next.Entitled(now),
+	})
+	if err != nil {
+		return err
+	}
+
+	canceledAt := sql.NullTime{}
+	if !next.CanceledAt.IsZero() {
+		canceledAt = sql.NullTime{Time: next.CanceledAt, Valid: true}
+	}
+	_, err = qtx.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:           userID,
+		Status:           string(next.Status),
+		CurrentPeriodEnd: next.CurrentPeriodEnd,
+		CanceledAt:       canceledAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// subscriptionExpirer moves subscriptions whose period ended without a
+// renewal to expired, which takes Chirpy Red away.
+type subscriptionExpirer struct {
+	cfg      *apiConfig
+	Interval time.Duration
+}
+
+// Run expires lapsed subscriptions every Interval until ctx is cancelled.
+func (e *subscriptionExpirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := e.ExpireLapsed(ctx)
+		if err != nil {
+			log.Printf("Error expiring subscriptions: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireLapsed expires one batch of lapsed subscriptions and returns how
+// many were expired.
+func (e *subscriptionExpirer) ExpireLapsed(ctx context.Context) (int, error) {
+	lapsed, err := e.cfg.db.ListLapsedSubscriptions(ctx, database.ListLapsedSubscriptionsParams{
+		Now:        time.Now().UTC(),
+		MaxResults: subscriptionExpiryBatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, sub := range lapsed {
+		// the state is checked again under the lock in case a renewal
+		// arrived since the list was read
+		err := e.cfg.updateSubscription(ctx, sub.UserID, func(state subscription.State, now time.Time) (subscription.State, bool, error) {
+			next, changed := state.Expire(now)
+			if changed {
+				expired++
+			}
+			return next, changed, nil
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}